@@ -0,0 +1,59 @@
+package m3ugen
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/adeynack/m3ugen/pkg/playlist"
+)
+
+// ChangesReport is a line of the changes reported with `report_changes_format: json`: the
+// difference of a playlist, encoded like `m3ugen diff -json`, and the path of that playlist.
+type ChangesReport struct {
+	Playlist string `json:"playlist"`
+	*playlist.Diff
+}
+
+// reportChanges compares the existing version of a playlist with the `paths` about to be
+// written, prints the difference (in the output's `report_changes_format`) and keeps it in `Changes`.
+func (r *ScanRun) reportChanges(output *OutputConfig, playlistPath string, paths []string) {
+	oldEntries, err := playlist.Read(playlistPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		oldEntries = nil
+	} else if err != nil {
+		r.verbose("Cannot compare with the previous version of %s: %v", playlistPath, err)
+		return
+	}
+
+	newEntries := make([]*playlist.Entry, len(paths))
+	for i, p := range paths {
+		if !isURL(p) {
+			if abs, err := filepath.Abs(p); err == nil {
+				p = abs
+			}
+		}
+		newEntries[i] = &playlist.Entry{Location: p}
+	}
+
+	diff := playlist.Compare(oldEntries, newEntries)
+	if r.Changes == nil {
+		r.Changes = make(map[string]*playlist.Diff)
+	}
+	r.Changes[playlistPath] = diff
+	if diff.Empty() {
+		r.verbose("No change in the entries of %s", playlistPath)
+		return
+	}
+	w := r.changesOutput
+	if w == nil {
+		w = os.Stderr
+	}
+	if output.ReportChangesFormat == ReportChangesJSON {
+		json.NewEncoder(w).Encode(&ChangesReport{Playlist: playlistPath, Diff: diff})
+		return
+	}
+	diff.WriteText(w, playlistPath+" (previous)", playlistPath)
+}
@@ -0,0 +1,108 @@
+package m3ugen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/adeynack/m3ugen/pkg/playlist"
+)
+
+const (
+	// ProblemMissing is reported for entries pointing at a file which does not exist.
+	ProblemMissing = "missing"
+	// ProblemUnreadable is reported for entries pointing at a file which cannot be read.
+	ProblemUnreadable = "unreadable"
+	// ProblemDuplicate is reported for entries already present earlier in the playlist.
+	ProblemDuplicate = "duplicate"
+	// ProblemWrongExtension is reported for entries which extension is not expected.
+	ProblemWrongExtension = "wrong_extension"
+)
+
+// Diagnostic is a problem found on an entry of a playlist.
+type Diagnostic struct {
+	// Playlist is the path of the checked playlist.
+	Playlist string `json:"playlist"`
+	// Line of the entry in the playlist.
+	Line int `json:"line"`
+	// Location of the entry, resolved against the playlist's folder.
+	Location string `json:"location"`
+	// Problem is one of `missing`, `unreadable`, `duplicate` or `wrong_extension`.
+	Problem string `json:"problem"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", d.Playlist, d.Line, d.Problem, d.Message)
+}
+
+// CheckPlaylist reads the M3U, PLS or XSPF playlist at `playlistPath` and reports its broken entries.
+// Entries are expected to have one of the `extensions` or, when empty, to be audio or video
+// files. URLs are only checked for duplicates.
+func CheckPlaylist(playlistPath string, extensions []string) ([]*Diagnostic, error) {
+	entries, err := playlist.Read(playlistPath)
+	if err != nil {
+		return nil, err
+	}
+	extensions = lowerCaseAll(extensions)
+
+	var diagnostics []*Diagnostic
+	report := func(entry *playlist.Entry, problem string, format string, args ...any) {
+		diagnostics = append(diagnostics, &Diagnostic{
+			Playlist: playlistPath,
+			Line:     entry.Line,
+			Location: entry.Location,
+			Problem:  problem,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	firstLines := make(map[string]int)
+	for _, entry := range entries {
+		if line, ok := firstLines[entry.Location]; ok {
+			report(entry, ProblemDuplicate, "%s is already listed on line %d", entry.Location, line)
+			continue
+		}
+		firstLines[entry.Location] = entry.Line
+
+		if isURL(entry.Location) {
+			continue
+		}
+		if problem, message := checkFile(entry.Location); problem != "" {
+			report(entry, problem, "%s", message)
+			continue
+		}
+		extension := strings.ToLower(fileExtension(entry.Location))
+		if len(extensions) > 0 && !slices.Contains(extensions, extension) {
+			report(entry, ProblemWrongExtension, "%s does not have one of the expected extensions (%s)",
+				entry.Location, strings.Join(extensions, ", "))
+		} else if len(extensions) == 0 && FileType(entry.Location) != FileTypeAudio && FileType(entry.Location) != FileTypeVideo {
+			report(entry, ProblemWrongExtension, "%s is not an audio or video file", entry.Location)
+		}
+	}
+	return diagnostics, nil
+}
+
+// checkFile returns the problem (and a message describing it) of a file which cannot be played.
+func checkFile(p string) (problem string, message string) {
+	info, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ProblemMissing, fmt.Sprintf("%s does not exist", p)
+	}
+	if err != nil {
+		return ProblemUnreadable, err.Error()
+	}
+	if info.IsDir() {
+		return ProblemUnreadable, fmt.Sprintf("%s is a folder", p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return ProblemUnreadable, err.Error()
+	}
+	f.Close()
+	return "", ""
+}
@@ -0,0 +1,38 @@
+package m3ugen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"a.mp3", "b.txt", "c.mp4"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0644))
+	}
+	playlist := filepath.Join(dir, "playlist.m3u")
+	require.NoError(t, os.WriteFile(playlist, []byte("#EXTM3U\na.mp3\nb.txt\nmissing.mp3\na.mp3\nc.mp4\nhttp://radio.example/live\n"), 0644))
+
+	diagnostics, err := CheckPlaylist(playlist, nil)
+	require.NoError(t, err)
+	problems := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		problems[i] = d.String()
+	}
+	assert.Equal(t, []string{
+		playlist + ":3: wrong_extension: " + filepath.Join(dir, "b.txt") + " is not an audio or video file",
+		playlist + ":4: missing: " + filepath.Join(dir, "missing.mp3") + " does not exist",
+		playlist + ":5: duplicate: " + filepath.Join(dir, "a.mp3") + " is already listed on line 2",
+	}, problems)
+
+	diagnostics, err = CheckPlaylist(playlist, []string{"MP3"})
+	require.NoError(t, err)
+	if assert.Len(t, diagnostics, 4) {
+		assert.Equal(t, ProblemWrongExtension, diagnostics[3].Problem)
+		assert.Equal(t, 6, diagnostics[3].Line)
+	}
+}
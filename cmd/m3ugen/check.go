@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/adeynack/m3ugen"
+)
+
+// runCheck validates playlists, reporting their broken entries. It exits with 1 when
+// problems are found (or playlists cannot be read), so it can be used in scheduled jobs.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: m3ugen check [options] playlist...")
+		flags.PrintDefaults()
+	}
+	jsonOutput := flags.Bool("json", false, "Output the diagnostics as JSON.")
+	var extensions stringList
+	flags.Var(&extensions, "ext", "Expected extension of the entries (repeatable). Default: any audio or video file.")
+	arguments, err := parseInterspersed(flags, args)
+	if err != nil || len(arguments) == 0 {
+		flags.Usage()
+		return 1
+	}
+
+	exitCode := 0
+	diagnostics := make([]*m3ugen.Diagnostic, 0)
+	for _, playlist := range arguments {
+		playlistDiagnostics, err := m3ugen.CheckPlaylist(playlist, extensions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error checking playlist: %v\n", err)
+			exitCode = 1
+			continue
+		}
+		diagnostics = append(diagnostics, playlistDiagnostics...)
+	}
+	if len(diagnostics) > 0 {
+		exitCode = 1
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diagnostics); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return exitCode
+	}
+	for _, d := range diagnostics {
+		fmt.Println(d)
+	}
+	return exitCode
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/adeynack/m3ugen"
+	"github.com/ghodss/yaml"
+)
+
+// configurationFile is the content of a configuration file: a configuration, the base files it
+// extends and its named profiles.
+type configurationFile struct {
+	m3ugen.Config
+	// Extends is the path (or list of paths) of the base configuration files, relative to this one.
+	Extends any `json:"extends"`
+	// Profiles are partial configurations, by name, applied on top of the file with `-profile`.
+	Profiles map[string]*m3ugen.Config `json:"profiles"`
+}
+
+// loadConfiguration returns the default configuration, overridden by the configuration file (if
+// any) then by the `M3UGEN_*` environment variables. Command line flags come on top of it. The
+// paths of a configuration file are expanded and resolved against its folder, also when it is
+// extended by another file. The values are not validated, as flags may still override them (see
+// `m3ugen.Config.ValidateValues`).
+func loadConfiguration(configurationFile string) (*m3ugen.Config, error) {
+	return loadConfigurationProfile(configurationFile, "")
+}
+
+// loadConfigurationProfile is `loadConfiguration`, applying the `profile` of the configuration
+// file (if not empty) after its base files and itself.
+func loadConfigurationProfile(configurationFile string, profile string) (*m3ugen.Config, error) {
+	conf := m3ugen.NewDefaultConfig()
+	if configurationFile != "" {
+		values, err := readConfigurationFile(configurationFile, nil)
+		if err != nil {
+			return nil, err
+		}
+		profiles, _ := values["profiles"].(map[string]any)
+		delete(values, "profiles")
+		if profile != "" {
+			profileValues, ok := profiles[profile].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown profile %q", configurationFile, profile)
+			}
+			values = mergeConfigurationValues(values, profileValues)
+		}
+		content, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(content, conf); err != nil {
+			return nil, fmt.Errorf("%s: %w", configurationFile, err)
+		}
+		// The paths are already expanded and resolved against the folder of the file they come
+		// from (see `resolveConfigurationPaths`), only the globs remain.
+		if err = conf.ExpandGlobs(); err != nil {
+			return nil, fmt.Errorf("%s: %w", configurationFile, err)
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requires a configuration file", profile)
+	}
+	if err := applyEnvironment(conf, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// readConfigurationFile returns the values of a configuration file merged on top of the ones of
+// the files it extends. `children` are the files being read which extend this one, to detect loops.
+func readConfigurationFile(path string, children []string) (map[string]any, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(children, absolutePath) {
+		return nil, fmt.Errorf("configuration %q extends itself (through %s)", path, strings.Join(children, " -> "))
+	}
+	children = append(children, absolutePath)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkKnownKeys(path, content, &configurationFile{}); err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	if err = yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var bases []string
+	switch extends := values["extends"].(type) {
+	case nil:
+	case string:
+		bases = []string{extends}
+	case []any:
+		for _, base := range extends {
+			if s, ok := base.(string); ok {
+				bases = append(bases, s)
+			} else {
+				return nil, fmt.Errorf("%s: extends requires paths, found %v", path, base)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: extends requires a path or a list of paths, found %v", path, extends)
+	}
+	delete(values, "extends")
+	resolveConfigurationPaths(values, filepath.Dir(absolutePath))
+
+	merged := make(map[string]any)
+	for _, base := range bases {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(path), base)
+		}
+		baseValues, err := readConfigurationFile(base, children)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeConfigurationValues(merged, baseValues)
+	}
+	return mergeConfigurationValues(merged, values), nil
+}
+
+// resolveConfigurationPaths expands the paths of the values of a configuration file (and of its
+// profiles) and resolves the relative ones against `baseDir`, the folder of the file, before they
+// are merged with the values of other files (see `m3ugen.ExpandPath`). `baseDir` is absolute, so
+// that the resolved paths do not depend on the current folder.
+func resolveConfigurationPaths(values map[string]any, baseDir string) {
+	resolvePathValue(values, "output", baseDir)
+	resolvePathValue(values, "scan", baseDir)
+	resolveOutputPaths(values, baseDir)
+	if outputs, ok := values["outputs"].([]any); ok {
+		for _, output := range outputs {
+			if output, ok := output.(map[string]any); ok {
+				resolvePathValue(output, "path", baseDir)
+				resolveOutputPaths(output, baseDir)
+			}
+		}
+	}
+	if profiles, ok := values["profiles"].(map[string]any); ok {
+		for _, profile := range profiles {
+			if profile, ok := profile.(map[string]any); ok {
+				resolveConfigurationPaths(profile, baseDir)
+			}
+		}
+	}
+}
+
+// resolveOutputPaths resolves the paths of the values of output options (see `m3ugen.OutputOptions`).
+func resolveOutputPaths(values map[string]any, baseDir string) {
+	for _, key := range []string{"template", "directory", "prepend", "append"} {
+		resolvePathValue(values, key, baseDir)
+	}
+	if history, ok := values["history"].(map[string]any); ok {
+		resolvePathValue(history, "path", baseDir)
+	}
+	if inserts, ok := values["inserts"].(map[string]any); ok {
+		resolvePathValue(inserts, "source", baseDir)
+	}
+	if rootWeights, ok := values["root_weights"].(map[string]any); ok {
+		resolved := make(map[string]any, len(rootWeights))
+		for root, weight := range rootWeights {
+			resolved[m3ugen.ExpandPath(root, baseDir)] = weight
+		}
+		values["root_weights"] = resolved
+	}
+}
+
+// resolvePathValue resolves the path, or the list of paths, of `values[key]`.
+func resolvePathValue(values map[string]any, key string, baseDir string) {
+	switch value := values[key].(type) {
+	case string:
+		values[key] = m3ugen.ExpandPath(value, baseDir)
+	case []any:
+		for i, p := range value {
+			if p, ok := p.(string); ok {
+				value[i] = m3ugen.ExpandPath(p, baseDir)
+			}
+		}
+	}
+}
+
+// mergeConfigurationValues returns the `base` values overridden by the `override` ones. Objects are
+// merged key by key; lists and scalar values are replaced as a whole.
+func mergeConfigurationValues(base map[string]any, override map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		baseObject, baseIsObject := merged[key].(map[string]any)
+		overrideObject, overrideIsObject := value.(map[string]any)
+		if baseIsObject && overrideIsObject {
+			merged[key] = mergeConfigurationValues(baseObject, overrideObject)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
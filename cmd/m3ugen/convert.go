@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/adeynack/m3ugen/pkg/playlist"
+)
+
+// runConvert converts a playlist from one format to another, the formats being chosen
+// from the files' extensions.
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: m3ugen convert [options] input output")
+		fmt.Fprintln(flags.Output(), "Supported formats: .m3u, .m3u8, .pls, .xspf")
+		flags.PrintDefaults()
+	}
+	var rebases stringList
+	flags.Var(&rebases, "rebase", "Replace a path prefix, as `from=to` (repeatable).")
+	relative := flags.Bool("relative", false, "Write the paths relative to the output playlist.")
+	arguments, err := parseInterspersed(flags, args)
+	if err != nil || len(arguments) != 2 {
+		flags.Usage()
+		return 1
+	}
+
+	entries, err := playlist.Read(arguments[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, rebase := range rebases {
+		from, to, found := strings.Cut(rebase, "=")
+		if !found {
+			fmt.Fprintf(os.Stderr, "invalid rebase %q: expected `from=to`\n", rebase)
+			return 1
+		}
+		playlist.Rebase(entries, from, to)
+	}
+	if err := playlist.Write(arguments[1], entries, playlist.WriteOptions{Relative: *relative}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/adeynack/m3ugen/pkg/playlist"
+)
+
+// runDiff reports the entries added, removed and moved between two playlists. Like `diff`, it
+// exits with 0 when they have the same entries, 1 when they differ and 2 on errors.
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: m3ugen diff [options] old new")
+		flags.PrintDefaults()
+	}
+	jsonOutput := flags.Bool("json", false, "Output the difference as JSON.")
+	arguments, err := parseInterspersed(flags, args)
+	if err != nil || len(arguments) != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	oldEntries, err := playlist.Read(arguments[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	newEntries, err := playlist.Read(arguments[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	diff := playlist.Compare(oldEntries, newEntries)
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	} else if !diff.Empty() {
+		err = diff.WriteText(os.Stdout, arguments[0], arguments[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if diff.Empty() {
+		return 0
+	}
+	return 1
+}
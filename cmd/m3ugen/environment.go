@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/adeynack/m3ugen"
+)
+
+// environmentPrefix is the prefix of the environment variables overriding the configuration.
+const environmentPrefix = "M3UGEN"
+
+// pathListKeys are the keys of the lists which environment variables are separated like the `PATH`
+// variable. Values of other lists may contain its separator (eg: the `:` of URLs).
+var pathListKeys = []string{"scan", "extensions"}
+
+// applyEnvironment overrides the fields of `conf` with the `M3UGEN_*` environment variables, as
+// returned by `lookup` (eg: `os.LookupEnv`). A variable is named after the field's key, upper-cased
+// (eg: `M3UGEN_OUTPUT`), and nested keys are joined with `_` (eg: `M3UGEN_HISTORY_PATH`). The
+// `pathListKeys` are separated like the `PATH` variable (eg: `M3UGEN_SCAN=/music:/podcasts`) and
+// other lists or objects are written in YAML (eg: `M3UGEN_PREPEND=[http://radio.example/intro.mp3]`
+// or `M3UGEN_ROOT_WEIGHTS={/music: 60, /podcasts: 40}`).
+func applyEnvironment(conf *m3ugen.Config, lookup func(key string) (string, bool)) error {
+	for _, field := range configFields(conf) {
+		key := environmentPrefix + "_" + strings.ToUpper(strings.Join(field.keys, "_"))
+		value, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if len(field.keys) == 1 && slices.Contains(pathListKeys, field.keys[0]) {
+			field.value.Set(reflect.ValueOf(filepath.SplitList(value)))
+		} else if err := setFieldValue(field.value, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/adeynack/m3ugen"
+	"github.com/ghodss/yaml"
+)
+
+// configField is a field of the configuration which can be overridden by an environment variable
+// or a flag.
+type configField struct {
+	// keys leading to the field in a configuration file (eg: `history`, `path`).
+	keys  []string
+	value reflect.Value
+}
+
+// configFields returns the fields of `conf`, by their keys. Objects (eg: `history`) are walked into
+// and embedded fields are flattened, while lists (eg: `outputs`) and maps are fields of their own.
+func configFields(conf *m3ugen.Config) []configField {
+	return structFields(reflect.ValueOf(conf).Elem(), nil, nil)
+}
+
+func structFields(v reflect.Value, keys []string, fields []configField) []configField {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct { // embedded fields are flattened
+			fields = structFields(v.Field(i), keys, fields)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldKeys := append(keys[:len(keys):len(keys)], name)
+		if field.Type.Kind() == reflect.Struct {
+			fields = structFields(v.Field(i), fieldKeys, fields)
+			continue
+		}
+		fields = append(fields, configField{keys: fieldKeys, value: v.Field(i)})
+	}
+	return fields
+}
+
+// setFieldValue parses `value` as the type of the field `v`. Lists and objects are written in YAML.
+func setFieldValue(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return yaml.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+	return nil
+}
+
+// isTextList indicates if the field `v` is a list of texts.
+func isTextList(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// stringList is a repeatable command line flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseInterspersed parses the flags of `args`, which can come before or after the other arguments
+// (eg: `m3ugen generate radio.yaml -maximum 5`), and returns these other arguments. Everything
+// after `--` is an argument.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var arguments []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return arguments, nil
+		}
+		if parsed := args[:len(args)-len(rest)]; len(parsed) > 0 && parsed[len(parsed)-1] == "--" {
+			return append(arguments, rest...), nil
+		}
+		arguments = append(arguments, rest[0])
+		args = rest[1:]
+	}
+}
+
+// firstArgument returns the first of `arguments`, or an empty text when there is none.
+func firstArgument(arguments []string) string {
+	if len(arguments) == 0 {
+		return ""
+	}
+	return arguments[0]
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/adeynack/m3ugen"
+)
+
+// flagAliases are shorter names of some configuration flags.
+var flagAliases = map[string]string{
+	"ext": "extensions",
+}
+
+// configFlags are the command line flags overriding the fields of the configuration. There is a
+// flag for every field, named after its key with `-` instead of `_`, nested keys being joined with
+// `-` (eg: `-maximum`, `-read-tags`, `-history-path`).
+type configFlags struct {
+	flags   *flag.FlagSet
+	profile string
+	// values of the flags, by name (aliases excluded).
+	values map[string]*configFlag
+}
+
+// configFlag is the flag of a field of the configuration. Lists of texts (eg: `-scan`) are
+// repeatable, and other lists or objects are written in YAML (eg: `-root-weights '{/music: 60}'`).
+type configFlag struct {
+	field  reflect.Value
+	set    bool
+	values []string
+}
+
+func (f *configFlag) String() string {
+	return strings.Join(f.values, ",")
+}
+
+func (f *configFlag) Set(value string) error {
+	if f.field.IsValid() && isTextList(f.field) {
+		f.values = append(f.values, value)
+	} else {
+		// Check the value now, so that it is reported with the usage of the flags.
+		if err := setFieldValue(reflect.New(f.field.Type()).Elem(), value); err != nil {
+			return err
+		}
+		f.values = []string{value}
+	}
+	f.set = true
+	return nil
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.field.IsValid() && f.field.Kind() == reflect.Bool
+}
+
+// flagName returns the name of the flag of a field.
+func flagName(field configField) string {
+	return strings.ReplaceAll(strings.Join(field.keys, "-"), "_", "-")
+}
+
+func addConfigFlags(flags *flag.FlagSet) *configFlags {
+	c := &configFlags{flags: flags, values: make(map[string]*configFlag)}
+	flags.StringVar(&c.profile, "profile", "", "Profile of the configuration file to apply.")
+	for _, field := range configFields(new(m3ugen.Config)) {
+		name := flagName(field)
+		usage := fmt.Sprintf("Overrides %q of the configuration.", strings.Join(field.keys, "."))
+		switch {
+		case isTextList(field.value):
+			usage += " Repeatable, replaces the configured values."
+		case field.value.Kind() == reflect.Slice || field.value.Kind() == reflect.Map:
+			usage += " Written in YAML."
+		}
+		c.values[name] = &configFlag{field: field.value}
+		flags.Var(c.values[name], name, usage)
+	}
+	for alias, name := range flagAliases {
+		flags.Var(c.values[name], alias, fmt.Sprintf("Alias of -%s.", name))
+	}
+	return c
+}
+
+// apply sets the fields of `conf` which flags were given on the command line.
+func (c *configFlags) apply(conf *m3ugen.Config) error {
+	for _, field := range configFields(conf) {
+		name := flagName(field)
+		f := c.values[name]
+		if f == nil || !f.set {
+			continue
+		}
+		if isTextList(field.value) {
+			field.value.Set(reflect.ValueOf(slices.Clone(f.values)).Convert(field.value.Type()))
+			continue
+		}
+		if err := setFieldValue(field.value, f.values[0]); err != nil {
+			return fmt.Errorf("invalid -%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// load returns the configuration of the optional `configurationFile`, with the selected profile
+// (see `loadConfigurationProfile`), overridden by the flags. Its values are validated once the
+// flags are applied.
+func (c *configFlags) load(configurationFile string) (*m3ugen.Config, error) {
+	conf, err := loadConfigurationProfile(configurationFile, c.profile)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.apply(conf); err != nil {
+		return nil, err
+	}
+	if err = conf.ValidateValues(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// runGenerate scans the folders and generates the playlists of a configuration. Without
+// configuration file, the flags describe a single playlist.
+func runGenerate(args []string) int {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: m3ugen generate [options] [configuration_file.yaml]")
+		flags.PrintDefaults()
+	}
+	configFlags := addConfigFlags(flags)
+	arguments, err := parseInterspersed(flags, args)
+	if err != nil || len(arguments) > 1 {
+		flags.Usage()
+		return 1
+	}
+
+	conf, err := configFlags.load(firstArgument(arguments))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration: %v\n", err)
+		return 1
+	}
+	if _, err = m3ugen.Start(conf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -1,42 +1,49 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
-
-	"github.com/adeynack/m3ugen"
-	"github.com/ghodss/yaml"
+	"sort"
+	"strings"
 )
 
-func main() {
-	flag.Parse()
-	configurationFile := flag.Arg(0)
-	if configurationFile == "" {
-		fmt.Fprintln(os.Stderr, "no configuration file provided")
-		os.Exit(1)
-	}
+// commands are the sub-commands, by name. They receive the arguments following their
+// name and return the exit code of the process.
+var commands = map[string]func(args []string) int{
+	"check":     runCheck,
+	"convert":   runConvert,
+	"diff":      runDiff,
+	"generate":  runGenerate,
+	"intersect": setOperationCommand("intersect"),
+	"repair":    runRepair,
+	"stats":     runStats,
+	"subtract":  setOperationCommand("subtract"),
+	"union":     setOperationCommand("union"),
+	"version":   runVersion,
+}
 
-	conf, err := loadConfiguration(configurationFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error loading configuration: %v\n", err)
-		os.Exit(1)
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			os.Exit(command(args[1:]))
+		}
 	}
 
-	if _, err = m3ugen.Start(conf); err != nil {
-		fmt.Fprintln(os.Stderr, err)
+	// Without command, the arguments are the configuration file to generate and its flags (as `generate`).
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: m3ugen [options] configuration_file.yaml (see `m3ugen generate -h` for the options)")
+		fmt.Fprintf(os.Stderr, "   or: m3ugen command [options] [arguments], with command one of: %s\n", strings.Join(commandNames(), ", "))
 		os.Exit(1)
 	}
+	os.Exit(runGenerate(args))
 }
 
-func loadConfiguration(configurationFile string) (*m3ugen.Config, error) {
-	content, err := os.ReadFile(configurationFile)
-	if err != nil {
-		return nil, err
-	}
-	conf := m3ugen.NewDefaultConfig()
-	if err = yaml.Unmarshal(content, conf); err != nil {
-		return nil, err
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-	return conf, nil
+	sort.Strings(names)
+	return names
 }
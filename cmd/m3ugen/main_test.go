@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adeynack/m3ugen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigurationFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func Test_LoadConfiguration_FileOverridesDefaults(t *testing.T) {
+	path := writeConfigurationFile(t, "output: /playlists/file.m3u\nscan: [/music]\nmaximum: 10\n")
+	conf, err := loadConfiguration(path)
+	require.NoError(t, err)
+	assert.Equal(t, "/playlists/file.m3u", conf.OutputPath)
+	assert.Equal(t, []string{"/music"}, conf.ScanFolders)
+	assert.Equal(t, 10, conf.MaximumEntries)
+	assert.Equal(t, 4, conf.ScanFolderWorkers, "default")
+}
+
+func Test_LoadConfiguration_EnvironmentOverridesFile(t *testing.T) {
+	t.Setenv("M3UGEN_OUTPUT", "env.m3u")
+	t.Setenv("M3UGEN_SCAN", strings.Join([]string{"/music", "/podcasts"}, string(os.PathListSeparator)))
+	t.Setenv("M3UGEN_RANDOMIZE", "true")
+	t.Setenv("M3UGEN_FORMAT", "extm3u")
+	t.Setenv("M3UGEN_HISTORY_RUNS", "3")
+	t.Setenv("M3UGEN_ROOT_WEIGHTS", "{/music: 60, /podcasts: 40}")
+
+	conf, err := loadConfiguration(writeConfigurationFile(t, "output: file.m3u\nscan: [/videos]\nmaximum: 10\nhistory:\n  path: /var/history.jsonl\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "env.m3u", conf.OutputPath)
+	assert.Equal(t, []string{"/music", "/podcasts"}, conf.ScanFolders)
+	assert.True(t, conf.RandomizeList)
+	assert.Equal(t, "extm3u", conf.Format, "embedded output options")
+	assert.Equal(t, "/var/history.jsonl", conf.History.Path, "from the file")
+	assert.Equal(t, 3, conf.History.Runs, "nested key")
+	assert.Equal(t, map[string]float64{"/music": 60, "/podcasts": 40}, conf.RootWeights)
+	assert.Equal(t, 10, conf.MaximumEntries, "from the file")
+}
+
+func Test_LoadConfiguration_EnvironmentLists(t *testing.T) {
+	t.Setenv("M3UGEN_EXTENSIONS", strings.Join([]string{"mp3", "ogg"}, string(os.PathListSeparator)))
+	t.Setenv("M3UGEN_PREPEND", "[http://radio.example/intro.mp3, /music/jingle.mp3]")
+	t.Setenv("M3UGEN_APPEND", "[https://radio.example:8000/outro.mp3]")
+
+	conf, err := loadConfiguration("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mp3", "ogg"}, conf.Extensions, "separated like PATH")
+	assert.Equal(t, []string{"http://radio.example/intro.mp3", "/music/jingle.mp3"}, conf.Prepend, "written in YAML")
+	assert.Equal(t, []string{"https://radio.example:8000/outro.mp3"}, conf.Append)
+}
+
+func Test_LoadConfiguration_WithoutFile(t *testing.T) {
+	t.Setenv("M3UGEN_OUTPUT", "env.m3u")
+	conf, err := loadConfiguration("")
+	require.NoError(t, err)
+	assert.Equal(t, "env.m3u", conf.OutputPath)
+	assert.Equal(t, 1024, conf.ChannelsBufferSize, "default")
+}
+
+func Test_LoadConfiguration_InvalidEnvironment(t *testing.T) {
+	t.Setenv("M3UGEN_MAXIMUM", "lots")
+	_, err := loadConfiguration("")
+	assert.EqualError(t, err, `invalid M3UGEN_MAXIMUM: strconv.ParseInt: parsing "lots": invalid syntax`)
+}
+
+func Test_FlagsOverrideEnvironment(t *testing.T) {
+	t.Setenv("M3UGEN_OUTPUT", "env.m3u")
+	t.Setenv("M3UGEN_MAXIMUM", "5")
+	t.Setenv("M3UGEN_RANDOMIZE", "true")
+	path := writeConfigurationFile(t, "output: file.m3u\nscan: [/videos]\n")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	configFlags := addConfigFlags(flags)
+	a, b := t.TempDir(), t.TempDir()
+	arguments, err := parseInterspersed(flags, []string{"-output", "flag.m3u", "-randomize=false", "-scan", a, "-scan", b, path,
+		"-ext", "mp3", "-history-path", "/var/history.jsonl", "-root-weights", "{" + a + ": 2}", "-read-tags"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, arguments, "flags can come after the configuration file")
+	conf, err := configFlags.load(firstArgument(arguments))
+	require.NoError(t, err)
+	assert.Equal(t, "flag.m3u", conf.OutputPath)
+	assert.False(t, conf.RandomizeList)
+	assert.Equal(t, []string{a, b}, conf.ScanFolders, "the folders of the file, which do not exist, are not validated")
+	assert.Equal(t, []string{"mp3"}, conf.Extensions, "alias")
+	assert.Equal(t, "/var/history.jsonl", conf.History.Path, "nested key")
+	assert.Equal(t, map[string]float64{a: 2}, conf.RootWeights)
+	assert.True(t, conf.ReadTags)
+	assert.Equal(t, 5, conf.MaximumEntries, "from the environment")
+}
+
+func Test_FlagsValidateValues(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	configFlags := addConfigFlags(flags)
+	require.NoError(t, flags.Parse([]string{"-scan-folder-workers", "0"}))
+	_, err := configFlags.load("")
+	assert.EqualError(t, err, "configuration requires at least one scan folder worker (ScanFolderWorkers)")
+
+	flags = flag.NewFlagSet("test", flag.ContinueOnError)
+	configFlags = addConfigFlags(flags)
+	require.NoError(t, flags.Parse([]string{"-where", "year >= 19x0"}))
+	_, err = configFlags.load("")
+	assert.ErrorContains(t, err, "invalid where expression: column 9: ")
+}
+
+func Test_FlagsForEveryField(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	addConfigFlags(flags)
+	for _, field := range configFields(new(m3ugen.Config)) {
+		assert.NotNil(t, flags.Lookup(flagName(field)), "no flag for %q", strings.Join(field.keys, "."))
+	}
+	assert.NotNil(t, flags.Lookup("outputs"))
+	assert.NotNil(t, flags.Lookup("inserts-every-minutes"))
+
+	flags.SetOutput(io.Discard)
+	_, err := parseInterspersed(flags, []string{"config.yaml", "-maximum", "lots"})
+	assert.EqualError(t, err, `invalid value "lots" for flag -maximum: strconv.ParseInt: parsing "lots": invalid syntax`)
+	arguments, err := parseInterspersed(flags, []string{"-maximum", "3", "--", "-config.yaml"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-config.yaml"}, arguments)
+}
+
+func Test_LoadConfiguration_UnknownKeys(t *testing.T) {
+	path := writeConfigurationFile(t, "output: file.m3u\nextentions: [mp3]\noutputs:\n  - path: a.m3u\n    maximun: 3\n    root_weights: {/music: 1}\n    history: {path: h.jsonl, rnus: 2}\n")
+	_, err := loadConfiguration(path)
+	assert.EqualError(t, err, path+`:2:1: unknown key "extentions", did you mean "extensions"?`+"\n"+
+		path+`:5:5: unknown key "maximun", did you mean "maximum"?`+"\n"+
+		path+`:7:30: unknown key "rnus", did you mean "runs"?`)
+}
+
+func Test_LoadConfiguration_ExtendsAndProfiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.yaml"), []byte(
+		"scan: [music, /podcasts]\nextensions: [mp3]\nmaximum: 10\nhistory:\n  path: history/base.jsonl\n  runs: 3\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "radio"), os.ModePerm))
+	path := filepath.Join(dir, "radio", "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"extends: ../base.yaml\noutput: radio.m3u\nhistory:\n  days: 2\n"+
+			"profiles:\n  short:\n    maximum: 3\n    history: {runs: 1}\n  mp3-and-ogg:\n    extensions: [mp3, ogg]\n    scan: [ogg]\n"), 0644))
+
+	conf, err := loadConfiguration(path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "radio", "radio.m3u"), conf.OutputPath)
+	assert.Equal(t, []string{filepath.Join(dir, "music"), "/podcasts"}, conf.ScanFolders, "relative to the base file")
+	assert.Equal(t, []string{"mp3"}, conf.Extensions, "from the base file")
+	assert.Equal(t, 10, conf.MaximumEntries)
+	assert.Equal(t, m3ugen.HistoryOptions{Path: filepath.Join(dir, "history", "base.jsonl"), Runs: 3, Days: 2}, conf.History, "objects are merged")
+
+	conf, err = loadConfigurationProfile(path, "short")
+	require.NoError(t, err)
+	assert.Equal(t, 3, conf.MaximumEntries)
+	assert.Equal(t, m3ugen.HistoryOptions{Path: filepath.Join(dir, "history", "base.jsonl"), Runs: 1, Days: 2}, conf.History)
+	assert.Equal(t, []string{"mp3"}, conf.Extensions)
+
+	conf, err = loadConfigurationProfile(path, "mp3-and-ogg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mp3", "ogg"}, conf.Extensions)
+	assert.Equal(t, []string{filepath.Join(dir, "radio", "ogg")}, conf.ScanFolders, "lists are replaced, relative to the file of the profile")
+
+	_, err = loadConfigurationProfile(path, "long")
+	assert.EqualError(t, err, path+`: unknown profile "long"`)
+}
+
+func Test_LoadConfiguration_ExtendsLoop(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")
+	require.NoError(t, os.WriteFile(a, []byte("extends: b.yaml\n"), 0644))
+	require.NoError(t, os.WriteFile(b, []byte("extends: [a.yaml]\n"), 0644))
+	_, err := loadConfiguration(a)
+	assert.EqualError(t, err, fmt.Sprintf("configuration %q extends itself (through %s -> %s)", a, a, b))
+}
+
+func Test_LoadConfiguration_RelativePaths(t *testing.T) {
+	path := writeConfigurationFile(t, "output: out.m3u\nscan: [music, /mnt/music]\n")
+	conf, err := loadConfiguration(path)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "out.m3u"), conf.OutputPath)
+	assert.Equal(t, []string{filepath.Join(filepath.Dir(path), "music"), "/mnt/music"}, conf.ScanFolders)
+
+	// With a relative configuration path, the paths are resolved once, against the absolute folder of the file.
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cfg"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cfg", "a.yaml"), []byte("output: out.m3u\nscan: [music, $M3UGEN_TEST_NAME]\n"), 0644))
+	t.Setenv("M3UGEN_TEST_NAME", "a$b")
+	workingDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(workingDir) })
+
+	conf, err = loadConfiguration(filepath.Join("cfg", "a.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "cfg", "out.m3u"), conf.OutputPath)
+	assert.Equal(t, []string{filepath.Join(dir, "cfg", "music"), filepath.Join(dir, "cfg", "a$b")}, conf.ScanFolders,
+		"the expanded variables are not expanded again")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/adeynack/m3ugen"
+)
+
+// runRepair relocates the missing entries of playlists using the `scan` folders of a configuration.
+// It exits with 1 when some entries could not be relocated.
+func runRepair(args []string) int {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: m3ugen repair -config configuration_file.yaml [options] playlist...")
+		flags.PrintDefaults()
+	}
+	configurationFile := flags.String("config", "", "Configuration file which `scan` folders are searched for the moved files.")
+	dryRun := flags.Bool("dry-run", false, "Only report, without rewriting the playlists.")
+	jsonOutput := flags.Bool("json", false, "Output the report as JSON.")
+	arguments, err := parseInterspersed(flags, args)
+	if err != nil || *configurationFile == "" || len(arguments) == 0 {
+		flags.Usage()
+		return 1
+	}
+
+	conf, err := loadConfiguration(*configurationFile)
+	if err == nil {
+		err = conf.ValidateValues()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration: %v\n", err)
+		return 1
+	}
+	reports, err := m3ugen.RepairPlaylists(conf, arguments, *dryRun)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	exitCode := 0
+	for _, report := range reports {
+		if len(report.Unresolved) > 0 {
+			exitCode = 1
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return exitCode
+	}
+	for _, report := range reports {
+		for _, entry := range report.Repaired {
+			fmt.Printf("%s:%d: repaired (%s): %s -> %s\n", report.Playlist, entry.Line, entry.Method, entry.From, entry.To)
+		}
+		for _, entry := range report.Unresolved {
+			fmt.Printf("%s:%d: unresolved: %s: %s\n", report.Playlist, entry.Line, entry.Location, entry.Reason)
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adeynack/m3ugen"
+	"github.com/adeynack/m3ugen/pkg/playlist"
+)
+
+// setOperations are the playlist set operations, by sub-command name.
+var setOperations = map[string]func(first []*playlist.Entry, others ...[]*playlist.Entry) []*playlist.Entry{
+	"union": func(first []*playlist.Entry, others ...[]*playlist.Entry) []*playlist.Entry {
+		return playlist.Union(append([][]*playlist.Entry{first}, others...)...)
+	},
+	"intersect": playlist.Intersect,
+	"subtract":  playlist.Difference,
+}
+
+// setOperationCommand returns the sub-command writing the result of a set operation over
+// playlists and scan results (configuration files) to a playlist.
+func setOperationCommand(name string) func(args []string) int {
+	return func(args []string) int {
+		flags := flag.NewFlagSet(name, flag.ExitOnError)
+		flags.Usage = func() {
+			fmt.Fprintf(flags.Output(), "Usage: m3ugen %s -o output [options] first second...\n", name)
+			fmt.Fprintln(flags.Output(), "Operands are playlists (.m3u, .m3u8, .pls, .xspf) or configuration files (.yaml, .yml, .json) which scan result is used.")
+			flags.PrintDefaults()
+		}
+		output := flags.String("o", "", "Path of the playlist to write.")
+		relative := flags.Bool("relative", false, "Write the paths relative to the output playlist.")
+		arguments, err := parseInterspersed(flags, args)
+		if err != nil || *output == "" || len(arguments) < 2 {
+			flags.Usage()
+			return 1
+		}
+
+		lists := make([][]*playlist.Entry, len(arguments))
+		for i, operand := range arguments {
+			entries, err := loadEntries(operand)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			lists[i] = entries
+		}
+		result := setOperations[name](lists[0], lists[1:]...)
+		if err := playlist.Write(*output, result, playlist.WriteOptions{Relative: *relative}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// loadEntries reads the entries of a playlist or, for a configuration file, scans its folders.
+func loadEntries(path string) ([]*playlist.Entry, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		conf, err := loadConfiguration(path)
+		if err == nil {
+			err = conf.ValidateValues()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error loading configuration: %w", err)
+		}
+		run, err := m3ugen.Scan(conf)
+		if err != nil {
+			return nil, err
+		}
+		entries := run.PlaylistEntries()
+		for _, e := range entries {
+			if abs, err := filepath.Abs(e.Location); err == nil && !e.IsURL() {
+				e.Location = abs
+			}
+		}
+		return entries, nil
+	default:
+		return playlist.Read(path)
+	}
+}
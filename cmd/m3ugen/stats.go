@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/adeynack/m3ugen"
+)
+
+// runStats scans the folders of a configuration and reports what was found, without generating any playlist.
+func runStats(args []string) int {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: m3ugen stats [options] [configuration_file.yaml]")
+		flags.PrintDefaults()
+	}
+	configFlags := addConfigFlags(flags)
+	jsonOutput := flags.Bool("json", false, "Output the statistics as JSON.")
+	arguments, err := parseInterspersed(flags, args)
+	if err != nil || len(arguments) > 1 {
+		flags.Usage()
+		return 1
+	}
+
+	conf, err := configFlags.load(firstArgument(arguments))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading configuration: %v\n", err)
+		return 1
+	}
+	r, err := m3ugen.Scan(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	stats := r.Stats()
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stats); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Printf("Files: %d\n", stats.Files)
+	if stats.Duration > 0 {
+		fmt.Printf("Known duration: %s\n", stats.Duration)
+	}
+	printCounts("By scan root", stats.ByRoot)
+	printCounts("By extension", stats.ByExtension)
+	return 0
+}
+
+// printCounts prints the counts, by decreasing number then name.
+func printCounts(title string, counts map[string]int) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	fmt.Printf("%s:\n", title)
+	for _, name := range names {
+		label := name
+		if label == "" {
+			label = "(other)"
+		}
+		fmt.Printf("  %s: %d\n", label, counts[name])
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkKnownKeys reports the keys of the YAML `content` which are not fields of `target` (as
+// named by their `json` tags, the ones `ghodss/yaml` uses), with their file, line and column.
+func checkKnownKeys(file string, content []byte, target any) error {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return errors.Join(unknownKeys(file, &document, reflect.TypeOf(target))...)
+}
+
+func unknownKeys(file string, node *yaml.Node, t reflect.Type) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		var errs []error
+		for _, child := range node.Content {
+			errs = append(errs, unknownKeys(file, child, t)...)
+		}
+		return errs
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return nil // type errors are reported when decoding
+		}
+		var errs []error
+		for _, item := range node.Content {
+			errs = append(errs, unknownKeys(file, item, t.Elem())...)
+		}
+		return errs
+	case yaml.MappingNode:
+		var errs []error
+		if t.Kind() == reflect.Map {
+			for i := 1; i < len(node.Content); i += 2 {
+				errs = append(errs, unknownKeys(file, node.Content[i], t.Elem())...)
+			}
+			return errs
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		fields := structKeys(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s:%d:%d: unknown key %q%s", file, key.Line, key.Column, key.Value, suggestKey(key.Value, fields)))
+				continue
+			}
+			errs = append(errs, unknownKeys(file, value, fieldType)...)
+		}
+		return errs
+	}
+	return nil
+}
+
+// structKeys returns the types of the fields of a struct by key, embedded structs being flattened.
+func structKeys(t reflect.Type) map[string]reflect.Type {
+	keys := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for key, fieldType := range structKeys(field.Type) {
+				keys[key] = fieldType
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		keys[name] = field.Type
+	}
+	return keys
+}
+
+// suggestKey returns a hint to the known key closest to a misspelled one, if any is close enough.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	var candidates []string
+	for candidate := range fields {
+		if editDistance(key, candidate) <= 2 {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return editDistance(key, candidates[i]) < editDistance(key, candidates[j]) ||
+			(editDistance(key, candidates[i]) == editDistance(key, candidates[j]) && candidates[i] < candidates[j])
+	})
+	return fmt.Sprintf(", did you mean %q?", candidates[0])
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// version of m3ugen, set when building a release (`-ldflags "-X main.version=..."`).
+var version = ""
+
+// runVersion prints the version of m3ugen.
+func runVersion(args []string) int {
+	v := version
+	if v == "" {
+		v = "(devel)"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+			v = info.Main.Version
+		}
+	}
+	fmt.Printf("m3ugen %s\n", v)
+	return 0
+}
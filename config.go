@@ -2,6 +2,9 @@ package m3ugen
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"slices"
 )
 
 // Config is the configuration a playlist generation needs to be performed.
@@ -25,12 +28,23 @@ type Config struct {
 	// If the tool should report duplicate entries in the detected files
 	// (the configured path could be duplicates or include one another).
 	DetectDuplicates bool `json:"detect_duplicates"`
+	// If the tags (artist, album, ...) of the found files should be read. Implicit
+	// when an output needs them (eg: `group_by`).
+	ReadTags bool `json:"read_tags"`
 	// Number of workers scanning the folders.
 	ScanFolderWorkers int `json:"scan_folder_workers"`
 	// Number of workers filtering the files.
 	ReceiveFilesWorkers int `json:"receive_files_workers"`
 	// Buffer size of the various Go channels used while scanning.
 	ChannelsBufferSize int `json:"channels_buffer_size"`
+	// Internet streams added to the scan result, after the found files. They are never
+	// filtered out by `extensions`.
+	Streams []*StreamConfig `json:"streams"`
+	// Options of the playlist described by the top-level `output`.
+	OutputOptions
+	// The list of playlists to generate from a single scan. When empty, a single playlist
+	// is generated from `output`, `extensions`, `randomize` and `maximum`.
+	Outputs []*OutputConfig `json:"outputs"`
 }
 
 // NewDefaultConfig creates a configuration with default values.
@@ -49,12 +63,144 @@ func NewDefaultConfig() *Config {
 	}
 }
 
+// Validate checks the whole configuration before generating its playlists: the presence of an
+// output and of something to scan, the values (see `ValidateValues`) and the outputs.
 func (c *Config) Validate() error {
-	if c.OutputPath == "" { // TODO: Make it so no output path = output to stdout
+	if c.OutputPath == "" && len(c.Outputs) == 0 { // TODO: Make it so no output path = output to stdout
 		return fmt.Errorf("configuration requires an output file path (OutputPath)")
 	}
-	if len(c.ScanFolders) < 1 {
+	if err := c.ValidateScan(); err != nil {
+		return err
+	}
+	outputs := c.EffectiveOutputs()
+	for i, output := range outputs {
+		if err := output.Validate(); err != nil {
+			return fmt.Errorf("invalid output #%d: %w", i+1, err)
+		}
+		if err := c.validateRootWeights(output); err != nil {
+			return fmt.Errorf("invalid output #%d: %w", i+1, err)
+		}
+		if c.Debug {
+			log.Default().Printf("Output #%d: path %q, directory %q", i+1, output.Path, output.Directory)
+		}
+	}
+	if len(c.Outputs) == 0 {
+		// The single output is a copy of the top-level options: keep what its validation parsed.
+		c.OutputOptions.parsedTemplate = outputs[0].parsedTemplate
+	}
+	return nil
+}
+
+// ValidateScan only validates the part of the configuration needed to scan.
+func (c *Config) ValidateScan() error {
+	if len(c.ScanFolders) < 1 && len(c.Streams) == 0 {
 		return fmt.Errorf("configuration requires at least one folder to scan (ScanFolders)")
 	}
+	if c.Debug {
+		for _, folder := range c.ScanFolders {
+			log.Default().Printf("Folder to scan: %q", folder)
+		}
+	}
+	return c.ValidateValues()
+}
+
+// ValidateValues checks the values which are set, whatever the configuration is used for: the `scan`
+// folders must exist, the worker counts must be positive, the buffer size cannot be negative and the
+// `where` expressions must be valid.
+// Unlike `Validate`, it does not require an output nor a folder to scan, so that it can check a
+// configuration as soon as it is loaded.
+func (c *Config) ValidateValues() error {
+	for _, folder := range c.ScanFolders {
+		info, err := os.Stat(folder)
+		if err != nil {
+			return fmt.Errorf("invalid folder to scan: %w", err)
+		}
+		if !info.IsDir() && !isPlaylistSource(folder) {
+			return fmt.Errorf("%q is neither a folder nor a playlist to scan (ScanFolders)", folder)
+		}
+	}
+	if c.ScanFolderWorkers < 1 {
+		return fmt.Errorf("configuration requires at least one scan folder worker (ScanFolderWorkers)")
+	}
+	if c.ReceiveFilesWorkers < 1 {
+		return fmt.Errorf("configuration requires at least one receive files worker (ReceiveFilesWorkers)")
+	}
+	if c.ChannelsBufferSize < 0 {
+		return fmt.Errorf("channels buffer size cannot be negative (ChannelsBufferSize)")
+	}
+	for i, stream := range c.Streams {
+		if err := stream.Validate(); err != nil {
+			return fmt.Errorf("invalid stream #%d: %w", i+1, err)
+		}
+	}
+	if _, err := c.OutputOptions.whereExpression(); err != nil {
+		return err
+	}
+	for i, output := range c.Outputs {
+		if _, err := output.whereExpression(); err != nil {
+			return fmt.Errorf("invalid output #%d: %w", i+1, err)
+		}
+	}
 	return nil
 }
+
+// validateRootWeights checks that the `root_weights` of an output are keyed by scan folders, as
+// written in `scan` (once expanded, see `ExpandPaths`).
+func (c *Config) validateRootWeights(output *OutputConfig) error {
+	roots := make([]string, 0, len(output.RootWeights))
+	for root := range output.RootWeights {
+		roots = append(roots, root)
+	}
+	slices.Sort(roots)
+	for _, root := range roots {
+		if !slices.Contains(c.ScanFolders, root) {
+			return fmt.Errorf("root weight %q does not match any folder to scan (ScanFolders)", root)
+		}
+	}
+	return nil
+}
+
+// EffectiveOutputs returns the playlists to generate: `Outputs` when configured, otherwise
+// the single playlist described by the top-level fields.
+func (c *Config) EffectiveOutputs() []*OutputConfig {
+	if len(c.Outputs) > 0 {
+		return c.Outputs
+	}
+	return []*OutputConfig{{
+		OutputOptions:  c.OutputOptions,
+		Path:           c.OutputPath,
+		Extensions:     c.Extensions,
+		RandomizeList:  c.RandomizeList,
+		MaximumEntries: c.MaximumEntries,
+	}}
+}
+
+// scanExtensions returns the lower-cased extensions the scan needs to consider. When the
+// top-level `extensions` is empty, it is the union of the extensions of all outputs, unless
+// one of them accepts any extension.
+func (c *Config) scanExtensions() []string {
+	if len(c.Extensions) > 0 || len(c.Outputs) == 0 {
+		return lowerCaseAll(c.Extensions)
+	}
+	var union []string
+	for _, output := range c.Outputs {
+		if len(output.Extensions) == 0 {
+			return nil
+		}
+		union = append(union, lowerCaseAll(output.Extensions)...)
+	}
+	return union
+}
+
+// needsTags indicates if the tags of the found files have to be read.
+func (c *Config) needsTags() bool {
+	if c.ReadTags {
+		return true
+	}
+	for _, output := range c.EffectiveOutputs() {
+		if output.Mode == ModeGroupBy || output.Inserts.EveryMinutes > 0 || output.Where != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package m3ugen
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ExportSchemaVersion is the version of the records written by the `jsonl` and `csv` formats.
+	// It changes whenever fields are renamed or removed (see `documentation/exportSchema.md`).
+	ExportSchemaVersion = 1
+
+	// FileTypeAudio is the type of audio files.
+	FileTypeAudio = "audio"
+	// FileTypeVideo is the type of video files.
+	FileTypeVideo = "video"
+	// FileTypeImage is the type of image files.
+	FileTypeImage = "image"
+	// FileTypePlaylist is the type of playlist files.
+	FileTypePlaylist = "playlist"
+	// FileTypeOther is the type of files with an unknown extension.
+	FileTypeOther = "other"
+)
+
+var fileTypesByExtension = map[string]string{
+	"aac": FileTypeAudio, "aiff": FileTypeAudio, "flac": FileTypeAudio, "m4a": FileTypeAudio, "mp3": FileTypeAudio,
+	"oga": FileTypeAudio, "ogg": FileTypeAudio, "opus": FileTypeAudio, "wav": FileTypeAudio, "wma": FileTypeAudio,
+	"avi": FileTypeVideo, "m4v": FileTypeVideo, "mkv": FileTypeVideo, "mov": FileTypeVideo, "mp4": FileTypeVideo,
+	"mpeg": FileTypeVideo, "mpg": FileTypeVideo, "webm": FileTypeVideo, "wmv": FileTypeVideo,
+	"bmp": FileTypeImage, "gif": FileTypeImage, "jpeg": FileTypeImage, "jpg": FileTypeImage, "png": FileTypeImage,
+	"webp": FileTypeImage,
+	"m3u":  FileTypePlaylist, "m3u8": FileTypePlaylist, "pls": FileTypePlaylist, "xspf": FileTypePlaylist,
+}
+
+var exportCSVHeader = []string{
+	"schema_version", "path", "root", "size", "mtime", "extension", "type", "duplicate_group",
+	"title", "artist", "album_artist", "album", "genre", "year", "duration_seconds",
+}
+
+// ExportRecord is an entry of the scan result, as written by the `jsonl` and `csv` formats.
+type ExportRecord struct {
+	SchemaVersion int       `json:"schema_version"`
+	Path          string    `json:"path"`
+	Root          string    `json:"root"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"mtime"`
+	Extension     string    `json:"extension"`
+	Type          string    `json:"type"`
+	// DuplicateGroup is shared by all records of a same file found more than once. 0 when unique.
+	DuplicateGroup int             `json:"duplicate_group"`
+	Metadata       *ExportMetadata `json:"metadata,omitempty"`
+}
+
+// ExportMetadata is the tag information of an `ExportRecord`.
+type ExportMetadata struct {
+	Title           string  `json:"title,omitempty"`
+	Artist          string  `json:"artist,omitempty"`
+	AlbumArtist     string  `json:"album_artist,omitempty"`
+	Album           string  `json:"album,omitempty"`
+	Genre           string  `json:"genre,omitempty"`
+	Year            int     `json:"year,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+}
+
+// FileType returns the type (`audio`, `video`, `image`, `playlist` or `other`) of a path, based on its extension.
+func FileType(p string) string {
+	if t, ok := fileTypesByExtension[strings.ToLower(fileExtension(p))]; ok {
+		return t
+	}
+	return FileTypeOther
+}
+
+// scanRootOf returns the configured scan folder `p` was found in.
+func (r *ScanRun) scanRootOf(p string) string {
+	root := ""
+	for _, folder := range r.Config.ScanFolders {
+		if (p == folder || strings.HasPrefix(p, strings.TrimSuffix(folder, "/")+"/")) && len(folder) > len(root) {
+			root = folder
+		}
+	}
+	return root
+}
+
+// duplicateGroups numbers the paths present more than once in the scan result.
+func (r *ScanRun) duplicateGroups() map[string]int {
+	counts := make(map[string]int)
+	for _, f := range r.FoundFilesPaths {
+		counts[f]++
+	}
+	groups := make(map[string]int)
+	for _, f := range r.FoundFilesPaths { // sorted, so the numbering is stable
+		if counts[f] > 1 && groups[f] == 0 {
+			groups[f] = len(groups) + 1
+		}
+	}
+	return groups
+}
+
+func (r *ScanRun) exportRecords(paths []string) []*ExportRecord {
+	groups := r.duplicateGroups()
+	records := make([]*ExportRecord, len(paths))
+	for i, p := range paths {
+		record := &ExportRecord{
+			SchemaVersion:  ExportSchemaVersion,
+			Path:           p,
+			Root:           r.scanRootOf(p),
+			Extension:      strings.ToLower(fileExtension(p)),
+			Type:           FileType(p),
+			DuplicateGroup: groups[p],
+		}
+		if info, err := os.Stat(p); err == nil {
+			record.Size = info.Size()
+			record.ModTime = info.ModTime().UTC()
+		}
+		if t := r.Metadata[p]; t != nil {
+			record.Metadata = &ExportMetadata{
+				Title:           t.Title,
+				Artist:          t.Artist,
+				AlbumArtist:     t.AlbumArtist,
+				Album:           t.Album,
+				Genre:           t.Genre,
+				Year:            t.Year,
+				DurationSeconds: t.Duration.Seconds(),
+			}
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// renderExport writes the records of `paths` as JSON Lines or CSV.
+func (r *ScanRun) renderExport(w io.Writer, format string, paths []string) error {
+	records := r.exportRecords(paths)
+	if format == FormatJSONLines {
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(exportCSVHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		metadata := record.Metadata
+		if metadata == nil {
+			metadata = &ExportMetadata{}
+		}
+		mtime := ""
+		if !record.ModTime.IsZero() {
+			mtime = record.ModTime.Format(time.RFC3339)
+		}
+		year := ""
+		if metadata.Year != 0 {
+			year = strconv.Itoa(metadata.Year)
+		}
+		duration := ""
+		if metadata.DurationSeconds != 0 {
+			duration = strconv.FormatFloat(metadata.DurationSeconds, 'f', -1, 64)
+		}
+		err := csvWriter.Write([]string{
+			strconv.Itoa(record.SchemaVersion),
+			record.Path,
+			record.Root,
+			strconv.FormatInt(record.Size, 10),
+			mtime,
+			record.Extension,
+			record.Type,
+			strconv.Itoa(record.DuplicateGroup),
+			metadata.Title,
+			metadata.Artist,
+			metadata.AlbumArtist,
+			metadata.Album,
+			metadata.Genre,
+			year,
+			duration,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,91 @@
+package m3ugen
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// FolderPlaylistName is the data given to `name_template` to name the playlist of a folder.
+type FolderPlaylistName struct {
+	// FolderName is the name of the folder (eg: `Abbey Road`).
+	FolderName string
+	// FolderPath is the full path of the folder (eg: `/music/The Beatles/Abbey Road`). As the name of
+	// the playlist is a file name, its separators are replaced (eg: `_music_The Beatles_Abbey Road`).
+	FolderPath string
+	// ParentName is the name of the folder's parent (eg: `The Beatles`).
+	ParentName string
+}
+
+func (o *OutputConfig) nameTemplate(defaultTemplate string) (*template.Template, error) {
+	text := o.NameTemplate
+	if text == "" {
+		text = defaultTemplate
+	}
+	tmpl, err := template.New("name_template").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// writeFolderPlaylists writes a playlist for every folder directly containing some of
+// the files of `fileList` and, if the output has a `path`, a playlist aggregating them all
+// (the only one including the URLs, eg: streams).
+func (r *ScanRun) writeFolderPlaylists(output *OutputConfig, fileList []string) error {
+	tmpl, err := output.nameTemplate(defaultFolderNameTemplate)
+	if err != nil {
+		return err
+	}
+
+	filesByFolder := make(map[string][]string)
+	for _, f := range fileList {
+		if isURL(f) { // streams have no folder, they are only in the aggregating playlist
+			continue
+		}
+		folder := filepath.Dir(f)
+		filesByFolder[folder] = append(filesByFolder[folder], f)
+	}
+	folders := make([]string, 0, len(filesByFolder))
+	for folder := range filesByFolder {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+
+	r.verbose("Writing a playlist for each of the %d folders containing files", len(folders))
+	for _, folder := range folders {
+		playlistPath, err := folderPlaylistPath(tmpl, output.Placement, folder)
+		if err != nil {
+			return err
+		}
+		if err := r.writePlaylistFile(output, playlistPath, filesByFolder[folder]); err != nil {
+			return err
+		}
+	}
+
+	if output.Path == "" {
+		return nil
+	}
+	r.verbose("Writing the playlist aggregating all folders")
+	return r.writePlaylistFile(output, output.Path, fileList)
+}
+
+func folderPlaylistPath(tmpl *template.Template, placement string, folder string) (string, error) {
+	name := new(strings.Builder)
+	data := FolderPlaylistName{
+		FolderName: filepath.Base(folder),
+		FolderPath: folder,
+		ParentName: filepath.Base(filepath.Dir(folder)),
+	}
+	if err := tmpl.Execute(name, data); err != nil {
+		return "", fmt.Errorf("error naming the playlist of folder %q: %w", folder, err)
+	}
+	// The name may contain separators (eg: from `FolderPath`), which must not nest nor escape the folder.
+	fileName := SanitizeFileName(name.String())
+	if placement == PlacementBeside {
+		return filepath.Join(filepath.Dir(folder), fileName), nil
+	}
+	return filepath.Join(folder, fileName), nil
+}
@@ -0,0 +1,159 @@
+package m3ugen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/adeynack/m3ugen/pkg/tags"
+)
+
+const (
+	unknownGroupValue = "Unknown"
+)
+
+// GroupPlaylistName is the data given to `name_template` to name the playlist of a group.
+type GroupPlaylistName struct {
+	// Field is the tag the entries are grouped by (eg: `genre`).
+	Field string
+	// Value is the value of the tag shared by the entries of the group, sanitized to be
+	// usable in a file name (eg: `Jazz`). `Unknown` for entries without this tag.
+	Value string
+}
+
+// groupByFields are the supported values of `group_by`, with how to get their value from tags.
+var groupByFields = map[string]func(t *tags.Tags) string{
+	"artist":       func(t *tags.Tags) string { return t.Artist },
+	"album_artist": func(t *tags.Tags) string { return FirstNonEmpty(t.AlbumArtist, t.Artist) },
+	"album":        func(t *tags.Tags) string { return t.Album },
+	"genre":        func(t *tags.Tags) string { return t.Genre },
+	"year": func(t *tags.Tags) string {
+		if t.Year == 0 {
+			return ""
+		}
+		return strconv.Itoa(t.Year)
+	},
+	"decade": func(t *tags.Tags) string {
+		if t.Year == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%ds", t.Year/10*10)
+	},
+}
+
+// readTags reads the tags of all found files into `Metadata`.
+func (r *ScanRun) readTags() {
+	r.verbose("Reading the tags of %d files", len(r.FoundFilesPaths))
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]*tags.Tags, len(r.FoundFilesPaths))
+	}
+	mutex := new(sync.Mutex)
+	pathsChan := make(chan string, r.Config.ChannelsBufferSize)
+	waitGroup := new(sync.WaitGroup)
+	waitGroup.Add(r.Config.ReceiveFilesWorkers)
+	for i := 0; i < r.Config.ReceiveFilesWorkers; i++ {
+		go func() {
+			defer waitGroup.Done()
+			for p := range pathsChan {
+				t, err := tags.ReadFile(p)
+				if err != nil {
+					r.debug("No tags read from %q: %v", p, err)
+					continue
+				}
+				mutex.Lock()
+				if fromPlaylist := r.Metadata[p]; fromPlaylist != nil {
+					t.Title = FirstNonEmpty(t.Title, fromPlaylist.Title)
+					if t.Duration == 0 {
+						t.Duration = fromPlaylist.Duration
+					}
+				}
+				r.Metadata[p] = t
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, p := range r.FoundFilesPaths {
+		if !isURL(p) {
+			pathsChan <- p
+		}
+	}
+	close(pathsChan)
+	waitGroup.Wait()
+	r.verbose("Tags found in %d files", len(r.Metadata))
+}
+
+// writeGroupPlaylists writes a playlist for every value of the `group_by` tag and, if the
+// output has a `path`, an index playlist listing them.
+func (r *ScanRun) writeGroupPlaylists(output *OutputConfig, fileList []string) error {
+	tmpl, err := output.nameTemplate(defaultGroupNameTemplate)
+	if err != nil {
+		return err
+	}
+
+	valueOf := groupByFields[output.GroupBy]
+	filesByGroup := make(map[string][]string)
+	for _, f := range fileList {
+		value := ""
+		if t := r.Metadata[f]; t != nil {
+			value = valueOf(t)
+		}
+		if value == "" {
+			value = unknownGroupValue
+		}
+		group := SanitizeFileName(value)
+		filesByGroup[group] = append(filesByGroup[group], f)
+	}
+	groups := make([]string, 0, len(filesByGroup))
+	for group := range filesByGroup {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	r.verbose("Writing a playlist for each of the %d values of %q", len(groups), output.GroupBy)
+	playlists := make([]string, 0, len(groups))
+	for _, group := range groups {
+		playlistPath, err := groupPlaylistPath(tmpl, output, group)
+		if err != nil {
+			return err
+		}
+		if err := r.writePlaylistFile(output, playlistPath, filesByGroup[group]); err != nil {
+			return err
+		}
+		playlists = append(playlists, playlistPath)
+	}
+
+	if output.Path == "" {
+		return nil
+	}
+	r.verbose("Writing the index of the %q playlists", output.GroupBy)
+	// The index points at playlists, not entries: it is an M3U playlist whatever the output's format.
+	index := *output
+	if index.Format != FormatExtendedM3U {
+		index.Format = FormatM3U
+	}
+	content := new(bytes.Buffer)
+	if err := r.renderPlaylist(content, &index, output.Path, playlists); err != nil {
+		return err
+	}
+	return r.writeFileIfChanged(output.Path, content.Bytes())
+}
+
+func groupPlaylistPath(tmpl *template.Template, output *OutputConfig, group string) (string, error) {
+	name := new(strings.Builder)
+	if err := tmpl.Execute(name, GroupPlaylistName{Field: output.GroupBy, Value: group}); err != nil {
+		return "", fmt.Errorf("error naming the playlist of %s %q: %w", output.GroupBy, group, err)
+	}
+	if output.Directory != "" {
+		if err := os.MkdirAll(output.Directory, os.ModePerm); err != nil {
+			return "", err
+		}
+	}
+	// Like the group, the rest of the name must not nest nor escape the directory.
+	return filepath.Join(output.Directory, SanitizeFileName(name.String())), nil
+}
@@ -0,0 +1,101 @@
+package m3ugen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GroupByPlaylists(t *testing.T) {
+	dir := t.TempDir()
+	music := filepath.Join(dir, "music")
+	require.NoError(t, os.Mkdir(music, os.ModePerm))
+	writeID3v1File(t, filepath.Join(music, "a.mp3"), "", "AC/DC", "1979")
+	writeID3v1File(t, filepath.Join(music, "b.mp3"), "", "Miles Davis", "1959")
+	writeID3v1File(t, filepath.Join(music, "c.mp3"), "", "AC/DC", "1980")
+	require.NoError(t, os.WriteFile(filepath.Join(music, "d.mp3"), []byte("no tags"), 0644))
+
+	config := NewDefaultConfig()
+	config.ScanFolders = []string{music}
+	config.Outputs = []*OutputConfig{
+		{
+			Path: filepath.Join(dir, "artists.m3u"),
+			OutputOptions: OutputOptions{
+				Mode:          ModeGroupBy,
+				GroupBy:       "artist",
+				Directory:     filepath.Join(dir, "artists"),
+				RelativePaths: true,
+			},
+		},
+		{
+			OutputOptions: OutputOptions{
+				Mode:         ModeGroupBy,
+				GroupBy:      "decade",
+				NameTemplate: "{{.Field}} {{.Value}}.m3u",
+				Directory:    dir,
+			},
+		},
+		{
+			Path: filepath.Join(dir, "years.m3u"),
+			OutputOptions: OutputOptions{
+				Mode:         ModeGroupBy,
+				GroupBy:      "year",
+				NameTemplate: "years/{{.Value}}.jsonl",
+				Directory:    dir,
+				Format:       FormatJSONLines,
+			},
+		},
+	}
+	_, err := Start(config)
+	require.NoError(t, err)
+
+	index, err := parseGeneratedPlaylist(filepath.Join(dir, "artists.m3u"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			filepath.Join("artists", "AC_DC.m3u"),
+			filepath.Join("artists", "Miles Davis.m3u"),
+			filepath.Join("artists", "Unknown.m3u"),
+		}, index)
+	}
+
+	acdc, err := parseGeneratedPlaylist(filepath.Join(dir, "artists", "AC_DC.m3u"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{filepath.Join("..", "music", "a.mp3"), filepath.Join("..", "music", "c.mp3")}, acdc)
+	}
+
+	seventies, err := parseGeneratedPlaylist(filepath.Join(dir, "decade 1970s.m3u"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{filepath.Join(music, "a.mp3")}, seventies)
+	}
+
+	years, err := parseGeneratedPlaylist(filepath.Join(dir, "years.m3u"))
+	if assert.NoError(t, err, "the index should be an M3U playlist, whatever the format") {
+		assert.Equal(t, []string{
+			filepath.Join(dir, "years_1959.jsonl"),
+			filepath.Join(dir, "years_1979.jsonl"),
+			filepath.Join(dir, "years_1980.jsonl"),
+			filepath.Join(dir, "years_Unknown.jsonl"),
+		}, years)
+	}
+	assert.FileExists(t, filepath.Join(dir, "years_1979.jsonl"))
+}
+
+func Test_SanitizeFileName(t *testing.T) {
+	assert.Equal(t, "AC_DC", SanitizeFileName("AC/DC"))
+	assert.Equal(t, "What_ Me_", SanitizeFileName(" What? Me* "))
+	assert.Equal(t, "_", SanitizeFileName(".."))
+}
+
+// writeID3v1File writes a file only containing an ID3v1 tag.
+func writeID3v1File(t *testing.T, path string, title string, artist string, year string) {
+	block := make([]byte, 128)
+	copy(block, "TAG")
+	copy(block[3:], title)
+	copy(block[33:], artist)
+	copy(block[93:], year)
+	block[127] = 255
+	require.NoError(t, os.WriteFile(path, block, 0644))
+}
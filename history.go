@@ -0,0 +1,252 @@
+package m3ugen
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// HistoryExclude moves the recently played entries after all others, so they are only used
+	// when there are not enough other entries to reach `maximum`.
+	HistoryExclude = "exclude"
+	// HistoryDownWeight makes the recently played entries less likely to be picked.
+	HistoryDownWeight = "downweight"
+
+	// historyRecentWeight is the weight of recently played entries in `downweight` mode (others have 1).
+	historyRecentWeight = 0.1
+
+	historyLockRetryInterval = 50 * time.Millisecond
+	historyLockTimeout       = 10 * time.Second
+	historyStaleLockAge      = 10 * time.Minute
+)
+
+// HistoryOptions configure the play history of a playlist (see `documentation/historyFile.md`).
+type HistoryOptions struct {
+	// Path of the history file. No history is kept when empty.
+	Path string `json:"path"`
+	// Runs is the number of previous runs which entries are considered recently played.
+	Runs int `json:"runs"`
+	// Days is the number of days during which entries are considered recently played.
+	Days int `json:"days"`
+	// Mode is what happens to recently played entries when randomizing (`exclude` or `downweight`).
+	// Default: `exclude`.
+	Mode string `json:"mode"`
+}
+
+// historyRecord is a line of the history file: the entries of a generated playlist.
+type historyRecord struct {
+	Time     time.Time `json:"time"`
+	Playlist string    `json:"playlist"`
+	Paths    []string  `json:"paths"`
+}
+
+// Validate checks the history options.
+func (h *HistoryOptions) Validate() error {
+	switch h.Mode {
+	case "", HistoryExclude, HistoryDownWeight:
+	default:
+		return fmt.Errorf("unknown history mode %q", h.Mode)
+	}
+	if h.Runs < 0 || h.Days < 0 {
+		return fmt.Errorf("history runs and days cannot be negative")
+	}
+	if h.Path != "" && h.Runs == 0 && h.Days == 0 {
+		return fmt.Errorf("history requires a number of runs or days")
+	}
+	return nil
+}
+
+// recentlyPlayed returns the paths of the history's records within the last `Runs` runs or `Days` days.
+// The lock of the history must be held (see `withFileLock`).
+func (h *HistoryOptions) recentlyPlayed(now time.Time) (map[string]bool, error) {
+	records, err := readHistory(h.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	recent := make(map[string]bool)
+	for i, record := range records {
+		withinRuns := h.Runs > 0 && i >= len(records)-h.Runs
+		withinDays := h.Days > 0 && now.Sub(record.Time) < time.Duration(h.Days)*24*time.Hour
+		if withinRuns || withinDays {
+			for _, p := range record.Paths {
+				recent[p] = true
+			}
+		}
+	}
+	return recent, nil
+}
+
+// record appends the entries of a generated playlist to the history, dropping the records
+// which are neither within the last `Runs` runs nor the last `Days` days. The lock of the history
+// must be held (see `withFileLock`).
+func (h *HistoryOptions) record(now time.Time, playlistPath string, paths []string) error {
+	records, err := readHistory(h.Path)
+	if err != nil {
+		return err
+	}
+	records = append(records, &historyRecord{Time: now.UTC(), Playlist: playlistPath, Paths: paths})
+	kept := make([]*historyRecord, 0, len(records))
+	for i, record := range records {
+		if (h.Runs > 0 && i >= len(records)-h.Runs) || (h.Days > 0 && now.Sub(record.Time) < time.Duration(h.Days)*24*time.Hour) {
+			kept = append(kept, record)
+		}
+	}
+
+	// Write to a temporary file and rename it, so the history is never left half-written.
+	temporary := h.Path + ".tmp"
+	f, err := os.Create(temporary)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, record := range kept {
+		if err = encoder.Encode(record); err != nil {
+			break
+		}
+	}
+	err = FirstErr(err, w.Flush(), f.Close())
+	if err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return os.Rename(temporary, h.Path)
+}
+
+// playedEntries returns the `selected` entries which are in `written`, the entries written to a
+// playlist: these are in the same order, with the inserts in between and possibly truncated.
+// The inserts are not recorded as played.
+func playedEntries(written []string, selected []string) []string {
+	count := 0
+	for _, e := range written {
+		if count < len(selected) && e == selected[count] {
+			count++
+		}
+	}
+	return selected[:count]
+}
+
+func readHistory(path string) ([]*historyRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []*historyRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		record := new(historyRecord)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid history record: %w", path, lineNumber, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// withFileLock runs `f` while holding the lock of `path` (a `.lock` file next to it), so that
+// concurrent runs neither lose each other's records nor pick entries without seeing them. Locks older than `historyStaleLockAge`
+// are considered left behind by a crashed run and are taken over (see `takeOverStaleLock`).
+func withFileLock(path string, f func() error) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(historyLockTimeout)
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(lock, "%d\n", os.Getpid())
+			lock.Close()
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > historyStaleLockAge {
+			takeOverStaleLock(lockPath, info)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for the lock %s", lockPath)
+		}
+		time.Sleep(historyLockRetryInterval)
+	}
+	defer os.Remove(lockPath)
+	return f()
+}
+
+// takeOverStaleLock removes the `stale` lock found at `lockPath`. It is first renamed to a unique
+// name, so that when several runs take it over at once, only one of them removes it: a run which
+// renamed a lock created meanwhile by another one puts it back instead.
+func takeOverStaleLock(lockPath string, stale fs.FileInfo) {
+	takenOver := fmt.Sprintf("%s.%d-%d", lockPath, os.Getpid(), rand.Int63())
+	if err := os.Rename(lockPath, takenOver); err != nil {
+		return // already taken over by another run
+	}
+	// The modification time is compared too, as the stale lock's file may be reused once removed.
+	if info, err := os.Stat(takenOver); err == nil && (!os.SameFile(info, stale) || !info.ModTime().Equal(stale.ModTime())) {
+		os.Link(takenOver, lockPath) // fails only if yet another run holds the lock already
+	}
+	os.Remove(takenOver)
+}
+
+// shuffleWithHistory randomizes `fileList`, giving less chances to the `recent` entries to be first.
+func shuffleWithHistory(fileList []string, recent map[string]bool, mode string) {
+	if mode == HistoryDownWeight {
+		weights := make(map[string]float64, len(fileList))
+		for _, f := range fileList {
+			weights[f] = 1
+			if recent[f] {
+				weights[f] = historyRecentWeight
+			}
+		}
+		weightedShuffle(fileList, func(f string) float64 { return weights[f] })
+		return
+	}
+
+	ShuffleSlice(fileList)
+	sort.SliceStable(fileList, func(i, j int) bool {
+		return !recent[fileList[i]] && recent[fileList[j]]
+	})
+}
+
+// weightedShuffle randomizes `list` so that the chances of an element to come before another
+// are proportional to their weights (Efraimidis-Spirakis algorithm).
+func weightedShuffle[T any](list []T, weight func(T) float64) {
+	keys := make([]float64, len(list))
+	for i, e := range list {
+		keys[i] = math.Pow(rand.Float64(), 1/weight(e))
+	}
+	sort.Sort(&byKeysDescending[T]{list: list, keys: keys})
+}
+
+type byKeysDescending[T any] struct {
+	list []T
+	keys []float64
+}
+
+func (s *byKeysDescending[T]) Len() int           { return len(s.list) }
+func (s *byKeysDescending[T]) Less(i, j int) bool { return s.keys[i] > s.keys[j] }
+func (s *byKeysDescending[T]) Swap(i, j int) {
+	s.list[i], s.list[j] = s.list[j], s.list[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
@@ -0,0 +1,116 @@
+package m3ugen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HistoryExcludesRecentlyPlayed(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mpg", "mp4"}
+	config.RandomizeList = true
+	config.MaximumEntries = 4
+	config.OutputOptions = OutputOptions{History: HistoryOptions{
+		Path: filepath.Join(t.TempDir(), "history.jsonl"),
+		Runs: 1,
+	}}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, firstEntries []string) {
+		for run := 0; run < 3; run++ {
+			_, err := Start(config)
+			require.NoError(t, err)
+			entries, err := parseGeneratedPlaylist(config.OutputPath)
+			require.NoError(t, err)
+			require.Len(t, entries, 4)
+			for _, e := range entries {
+				assert.NotContains(t, firstEntries, e, "run %d repeated an entry of the previous run", run)
+			}
+			firstEntries = entries
+		}
+
+		records, err := readHistory(config.History.Path)
+		require.NoError(t, err)
+		assert.Len(t, records, 1, "only the last run should be kept")
+	})
+}
+
+func Test_HistoryConcurrentRecords(t *testing.T) {
+	history := &HistoryOptions{Path: filepath.Join(t.TempDir(), "history.jsonl"), Runs: 100}
+	waitGroup := new(sync.WaitGroup)
+	for i := 0; i < 20; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			assert.NoError(t, withFileLock(history.Path, func() error {
+				return history.record(time.Now(), "playlist.m3u", []string{fmt.Sprintf("file%d.mp3", i)})
+			}))
+		}(i)
+	}
+	waitGroup.Wait()
+
+	recent, err := history.recentlyPlayed(time.Now())
+	require.NoError(t, err)
+	assert.Len(t, recent, 20)
+}
+
+func Test_HistoryStaleLockTakenOverOnce(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "history.jsonl.lock")
+	require.NoError(t, os.WriteFile(lockPath, []byte("0\n"), 0644))
+	staleTime := time.Now().Add(-2 * historyStaleLockAge)
+	require.NoError(t, os.Chtimes(lockPath, staleTime, staleTime))
+	stale, err := os.Stat(lockPath)
+	require.NoError(t, err)
+
+	// Another run takes the stale lock over first, and creates its own.
+	takeOverStaleLock(lockPath, stale)
+	require.NoFileExists(t, lockPath)
+	require.NoError(t, os.WriteFile(lockPath, []byte("1\n"), 0644))
+
+	// The late run must leave it in place.
+	takeOverStaleLock(lockPath, stale)
+	content, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, "1\n", string(content))
+	leftovers, err := filepath.Glob(lockPath + ".*")
+	require.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func Test_HistoryConcurrentRuns(t *testing.T) {
+	dir := t.TempDir()
+	const runs = 10
+	for i := 0; i < runs; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.mp3", i)), nil, 0644))
+	}
+	playlists := t.TempDir()
+	historyPath := filepath.Join(playlists, "history.jsonl")
+
+	waitGroup := new(sync.WaitGroup)
+	for i := 0; i < runs; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			config := NewDefaultConfig()
+			config.ScanFolders = []string{dir}
+			config.Extensions = []string{"mp3"}
+			config.RandomizeList = true
+			config.MaximumEntries = 1
+			config.OutputPath = filepath.Join(playlists, fmt.Sprintf("playlist%d.m3u", i))
+			config.OutputOptions = OutputOptions{History: HistoryOptions{Path: historyPath, Runs: runs}}
+			_, err := Start(config)
+			assert.NoError(t, err)
+		}(i)
+	}
+	waitGroup.Wait()
+
+	// Each run saw the entries recorded by the previous ones, so none picked the same file.
+	recent, err := (&HistoryOptions{Path: historyPath, Runs: runs}).recentlyPlayed(time.Now())
+	require.NoError(t, err)
+	assert.Len(t, recent, runs)
+}
@@ -0,0 +1,128 @@
+package m3ugen
+
+import (
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adeynack/m3ugen/pkg/playlist"
+)
+
+const (
+	// InsertsRotation picks the inserts one after the other, starting over after the last one.
+	InsertsRotation = "rotation"
+	// InsertsRandom picks the inserts at random.
+	InsertsRandom = "random"
+)
+
+// InsertsOptions inject the entries of a separate source (eg: jingles) into a playlist at regular intervals.
+type InsertsOptions struct {
+	// Source of the inserts: a folder (scanned recursively) or a playlist (M3U, PLS or XSPF).
+	Source string `json:"source"`
+	// Every is the number of entries between two inserts.
+	Every int `json:"every"`
+	// EveryMinutes is the accumulated duration of the entries between two inserts. The duration
+	// of the entries is read from their tags; entries without a known duration count as 0.
+	EveryMinutes float64 `json:"every_minutes"`
+	// Order in which the inserts are picked (`rotation` or `random`). Default: `rotation`.
+	Order string `json:"order"`
+}
+
+// Validate checks the inserts options.
+func (i *InsertsOptions) Validate() error {
+	if i.Source == "" {
+		return nil
+	}
+	switch i.Order {
+	case "", InsertsRotation, InsertsRandom:
+	default:
+		return fmt.Errorf("unknown inserts order %q", i.Order)
+	}
+	if i.Every < 0 || i.EveryMinutes < 0 {
+		return fmt.Errorf("inserts intervals cannot be negative")
+	}
+	if (i.Every == 0) == (i.EveryMinutes == 0) {
+		return fmt.Errorf("inserts require either a number of entries (every) or of minutes (every_minutes)")
+	}
+	return nil
+}
+
+// readInserts returns the entries of the inserts' source, in order. Files of a folder are only
+// kept if they have one of the `extensions` (all of them when empty).
+func readInserts(source string, extensions []string) ([]string, error) {
+	if isPlaylistSource(source) {
+		entries, err := playlist.Read(source)
+		if err != nil {
+			return nil, err
+		}
+		inserts := make([]string, len(entries))
+		for i, e := range entries {
+			inserts[i] = e.Location
+		}
+		return inserts, nil
+	}
+
+	var inserts []string
+	err := filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && !strings.HasPrefix(d.Name(), ".") {
+			inserts = append(inserts, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	inserts = filterExtensions(inserts, extensions)
+	slices.Sort(inserts)
+	return inserts, nil
+}
+
+// outputInserts returns the entries of the inserts' source of an output, only reading it the first time.
+func (r *ScanRun) outputInserts(output *OutputConfig) ([]string, error) {
+	if inserts, ok := r.inserts[output]; ok {
+		return inserts, nil
+	}
+	inserts, err := readInserts(output.Inserts.Source, output.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	if r.inserts == nil {
+		r.inserts = make(map[*OutputConfig][]string)
+	}
+	r.inserts[output] = inserts
+	return inserts, nil
+}
+
+// insertEntries returns `fileList` with an entry of `inserts` after every `Every` entries or
+// `EveryMinutes` of accumulated duration.
+func (r *ScanRun) insertEntries(options *InsertsOptions, fileList []string, inserts []string) []string {
+	if len(inserts) == 0 {
+		return fileList
+	}
+	interval := time.Duration(options.EveryMinutes * float64(time.Minute))
+	withInserts := make([]string, 0, len(fileList)+len(fileList)/max(options.Every, 1))
+	count, accumulated, next := 0, time.Duration(0), 0
+	for _, f := range fileList {
+		withInserts = append(withInserts, f)
+		count++
+		if metadata := r.Metadata[f]; metadata != nil && metadata.Duration > 0 {
+			accumulated += metadata.Duration
+		}
+		if (options.Every > 0 && count >= options.Every) || (interval > 0 && accumulated >= interval) {
+			if options.Order == InsertsRandom {
+				next = rand.Intn(len(inserts))
+			}
+			withInserts = append(withInserts, inserts[next%len(inserts)])
+			next++
+			count, accumulated = 0, 0
+		}
+	}
+	return withInserts
+}
@@ -0,0 +1,88 @@
+package m3ugen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adeynack/m3ugen/pkg/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InsertsEveryEntries(t *testing.T) {
+	dir := t.TempDir()
+	music := filepath.Join(dir, "music")
+	jingles := filepath.Join(dir, "jingles")
+	for _, p := range []string{
+		filepath.Join(music, "a.mp3"), filepath.Join(music, "b.mp3"), filepath.Join(music, "c.mp3"),
+		filepath.Join(music, "d.mp3"), filepath.Join(music, "e.mp3"),
+		filepath.Join(jingles, "1.mp3"), filepath.Join(jingles, "2.mp3"), filepath.Join(jingles, "notes.txt"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.NoError(t, os.WriteFile(p, nil, 0644))
+	}
+
+	config := NewDefaultConfig()
+	config.ScanFolders = []string{music}
+	config.Extensions = []string{"mp3"}
+	config.OutputPath = filepath.Join(dir, "radio.m3u")
+	config.MaximumEntries = 7
+	config.Inserts = InsertsOptions{Source: jingles, Every: 2}
+	config.History = HistoryOptions{Path: filepath.Join(dir, "history.jsonl"), Runs: 1}
+	_, err := Start(config)
+	require.NoError(t, err)
+
+	entries, err := parseGeneratedPlaylist(config.OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(music, "a.mp3"), filepath.Join(music, "b.mp3"), filepath.Join(jingles, "1.mp3"),
+		filepath.Join(music, "c.mp3"), filepath.Join(music, "d.mp3"), filepath.Join(jingles, "2.mp3"),
+		filepath.Join(music, "e.mp3"),
+	}, entries)
+
+	records, err := readHistory(config.History.Path)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, []string{
+		filepath.Join(music, "a.mp3"), filepath.Join(music, "b.mp3"), filepath.Join(music, "c.mp3"),
+		filepath.Join(music, "d.mp3"), filepath.Join(music, "e.mp3"),
+	}, records[0].Paths, "the inserts should not be recorded as played")
+}
+
+func Test_InsertsReadOnce(t *testing.T) {
+	jingles := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(jingles, "1.mp3"), nil, 0644))
+	output := &OutputConfig{OutputOptions: OutputOptions{Inserts: InsertsOptions{Source: jingles, Every: 1}}}
+	r := &ScanRun{}
+	first, err := r.outputInserts(output)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(jingles, "1.mp3")}, first)
+
+	require.NoError(t, os.WriteFile(filepath.Join(jingles, "2.mp3"), nil, 0644))
+	second, err := r.outputInserts(output)
+	require.NoError(t, err)
+	assert.Equal(t, first, second, "the source should not be read again")
+}
+
+func Test_InsertsEveryMinutes(t *testing.T) {
+	r := &ScanRun{Metadata: map[string]*tags.Tags{
+		"a": {Duration: 4 * time.Minute},
+		"b": {Duration: 3 * time.Minute},
+		"c": {Duration: 10 * time.Minute},
+		"d": {Duration: 2 * time.Minute},
+	}}
+	options := &InsertsOptions{EveryMinutes: 6.5}
+
+	assert.Equal(t, []string{"a", "b", "J1", "c", "J2", "d", "unknown"},
+		r.insertEntries(options, []string{"a", "b", "c", "d", "unknown"}, []string{"J1", "J2"}))
+}
+
+func Test_InsertsValidation(t *testing.T) {
+	assert.EqualError(t, (&InsertsOptions{Source: "jingles"}).Validate(),
+		"inserts require either a number of entries (every) or of minutes (every_minutes)")
+	assert.EqualError(t, (&InsertsOptions{Source: "jingles", Every: 3, Order: "shuffle"}).Validate(),
+		`unknown inserts order "shuffle"`)
+	assert.NoError(t, (&InsertsOptions{Source: "jingles", EveryMinutes: 15, Order: InsertsRandom}).Validate())
+}
@@ -0,0 +1,442 @@
+package m3ugen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/adeynack/m3ugen/pkg/filter"
+)
+
+const (
+	// FormatM3U is a plain M3U playlist: one path per line.
+	FormatM3U = "m3u"
+	// FormatExtendedM3U is an extended M3U playlist (`#EXTM3U` header and `#EXTINF` lines).
+	FormatExtendedM3U = "extm3u"
+	// FormatJSONLines is an export of the scan result as JSON Lines (see `ExportRecord`).
+	FormatJSONLines = "jsonl"
+	// FormatCSV is an export of the scan result as CSV (see `ExportRecord`).
+	FormatCSV = "csv"
+	// FormatTemplate is a custom format, described by a Go `text/template` file (see `Template`).
+	FormatTemplate = "template"
+
+	// SortByPath orders the entries by their full path.
+	SortByPath = "path"
+	// SortByName orders the entries by their file name, regardless of their folder.
+	SortByName = "name"
+
+	// ModeSingle writes all the entries to the playlist at `path`.
+	ModeSingle = "single"
+	// ModePerFolder writes a playlist for every folder directly containing entries.
+	ModePerFolder = "per_folder"
+	// ModeGroupBy writes a playlist for every value of a tag (see `GroupBy`).
+	ModeGroupBy = "group_by"
+
+	// PlacementInside puts a folder's playlist inside that folder.
+	PlacementInside = "inside"
+	// PlacementBeside puts a folder's playlist next to that folder, in its parent.
+	PlacementBeside = "beside"
+
+	// ReportChangesText reports the changes of the playlists in a unified-diff like format (as `m3ugen diff`).
+	ReportChangesText = "text"
+	// ReportChangesJSON reports the changes of the playlists as JSON Lines (see `ChangesReport`).
+	ReportChangesJSON = "json"
+
+	defaultFolderNameTemplate = "{{.FolderName}}.m3u"
+	defaultGroupNameTemplate  = "{{.Value}}.m3u"
+)
+
+// OutputOptions are the options shaping a playlist, available both at the top-level
+// of the configuration and on each entry of `outputs`.
+type OutputOptions struct {
+	// Format of the playlist file (`m3u`, `extm3u`, `jsonl`, `csv` or `template`). Default: `m3u`.
+	Format string `json:"format"`
+	// Template is the path of the Go `text/template` file used by the `template` format. It can
+	// define the `header`, `entry` and `footer` templates, receiving respectively a `TemplatePlaylist`,
+	// a `TemplateEntry` for each entry, and a `TemplatePlaylist` again.
+	Template string `json:"template"`
+	// Sort is the order of the entries when they are not randomized (`path` or `name`). Default: `path`.
+	Sort string `json:"sort"`
+	// If the entries should be written relative to the playlist's folder. Always the case
+	// for the `per_folder` mode.
+	RelativePaths bool `json:"relative_paths"`
+	// Mode is how the entries are spread in playlists (`single`, `per_folder` or `group_by`). Default: `single`.
+	// In `per_folder` mode, `path` is optional and, if set, is a playlist aggregating all folders.
+	// In `group_by` mode, `path` is optional and, if set, is an index playlist of all generated playlists.
+	Mode string `json:"mode"`
+	// NameTemplate is the Go template of the playlists' file name. In `per_folder` mode, it receives
+	// a `FolderPlaylistName` (default: `{{.FolderName}}.m3u`). In `group_by` mode, it receives a
+	// `GroupPlaylistName` (default: `{{.Value}}.m3u`).
+	NameTemplate string `json:"name_template"`
+	// GroupBy is the tag splitting the entries in `group_by` mode (`artist`, `album_artist`,
+	// `album`, `genre`, `year` or `decade`).
+	GroupBy string `json:"group_by"`
+	// Directory where the playlists are written in `group_by` mode. Default: the current directory.
+	Directory string `json:"directory"`
+	// If the entries added, removed and moved since the previous version of the playlist should
+	// be reported on the console when writing it. Only for the `m3u` and `extm3u` formats.
+	ReportChanges bool `json:"report_changes"`
+	// ReportChangesFormat is the format of the reported changes (`text` or `json`). Default: `text`.
+	ReportChangesFormat string `json:"report_changes_format"`
+	// History keeps track of the generated entries, to avoid repeating recently played ones when randomizing.
+	History HistoryOptions `json:"history"`
+	// Placement of the playlists in `per_folder` mode (`inside` or `beside`). Default: `inside`.
+	Placement string `json:"placement"`
+	// RootWeights are the shares of the scan roots (as written in `scan`) in randomized playlists,
+	// eg: `{"/podcasts": 40, "/music": 60}`. Roots without a weight only fill in the remaining entries.
+	// Keys can be globs like in `scan`, each matching root getting the weight.
+	RootWeights map[string]float64 `json:"root_weights"`
+	// RootQuota is the maximum number of entries taken from each scan root. 0 means "no quota".
+	RootQuota int `json:"root_quota"`
+	// Interleave alternates the entries of the scan roots, in the order of `scan` (A1, B1, C1, A2, ...).
+	// Each root keeps the order of its entries (sorted, or randomized with `randomize`).
+	Interleave bool `json:"interleave"`
+	// InterleaveSorted keeps the entries of each root in `sort` order when interleaving, even with `randomize`.
+	InterleaveSorted bool `json:"interleave_sorted"`
+	// Inserts are entries injected at regular intervals (eg: jingles), before truncating to `maximum`.
+	Inserts InsertsOptions `json:"inserts"`
+	// Prepend are paths or URLs always written at the start of the playlist (eg: an intro), whatever the order.
+	Prepend []string `json:"prepend"`
+	// Append are paths or URLs always written at the end of the playlist (eg: a sign-off), whatever the order.
+	Append []string `json:"append"`
+	// If the `prepend` and `append` entries count toward `maximum`. By default, they come in addition to it.
+	PinnedInMaximum bool `json:"pinned_in_maximum"`
+	// Where is an expression selecting the entries from their path and tags, eg:
+	// `genre in ["Jazz", "Blues"] and year >= 1960`. See the `filter` package for the syntax.
+	Where string `json:"where"`
+
+	// parsedTemplate is the `template`, parsed once by `Validate` and reused for every playlist.
+	parsedTemplate *template.Template
+}
+
+// OutputConfig describes one playlist generated from the scan result.
+type OutputConfig struct {
+	OutputOptions
+	// The path of the output playlist.
+	Path string `json:"path"`
+	// List of extensions to filter for. If empty, do not filter on extensions.
+	Extensions []string `json:"extensions"`
+	// If the list should be written in sorted order (false) or in a randomised way (true).
+	RandomizeList bool `json:"randomize"`
+	// Maximum entries to output in the playlist. 0 or less means "no maximum".
+	MaximumEntries int `json:"maximum"`
+}
+
+// Validate checks that the output is complete and only uses known options.
+func (o *OutputConfig) Validate() error {
+	switch o.Mode {
+	case "", ModeSingle:
+		if o.Path == "" {
+			return fmt.Errorf("output requires a path")
+		}
+	case ModePerFolder:
+		if _, err := o.nameTemplate(defaultFolderNameTemplate); err != nil {
+			return err
+		}
+	case ModeGroupBy:
+		if _, ok := groupByFields[o.GroupBy]; !ok {
+			return fmt.Errorf("unknown group_by %q", o.GroupBy)
+		}
+		if _, err := o.nameTemplate(defaultGroupNameTemplate); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown mode %q", o.Mode)
+	}
+	switch o.ReportChangesFormat {
+	case "", ReportChangesText, ReportChangesJSON:
+	default:
+		return fmt.Errorf("unknown report_changes_format %q", o.ReportChangesFormat)
+	}
+	switch o.Placement {
+	case "", PlacementInside, PlacementBeside:
+	default:
+		return fmt.Errorf("unknown placement %q", o.Placement)
+	}
+	switch o.Format {
+	case "", FormatM3U, FormatExtendedM3U, FormatJSONLines, FormatCSV:
+	case FormatTemplate:
+		if _, err := o.playlistTemplate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q", o.Format)
+	}
+	switch o.Sort {
+	case "", SortByPath, SortByName:
+	default:
+		return fmt.Errorf("unknown sort %q", o.Sort)
+	}
+	for root, weight := range o.RootWeights {
+		if weight < 0 {
+			return fmt.Errorf("the weight of root %q cannot be negative", root)
+		}
+	}
+	if o.RootQuota < 0 {
+		return fmt.Errorf("root quota cannot be negative")
+	}
+	if o.Interleave && len(o.RootWeights) > 0 {
+		return fmt.Errorf("interleave and root_weights cannot be combined")
+	}
+	if err := o.Inserts.Validate(); err != nil {
+		return err
+	}
+	if _, err := o.whereExpression(); err != nil {
+		return err
+	}
+	for _, pinned := range append(slices.Clone(o.Prepend), o.Append...) {
+		if isURL(pinned) {
+			continue
+		}
+		if info, err := os.Stat(pinned); err != nil {
+			return fmt.Errorf("invalid pinned entry: %w", err)
+		} else if !info.Mode().IsRegular() {
+			return fmt.Errorf("pinned entry %q is not a file", pinned)
+		}
+	}
+	return o.History.Validate()
+}
+
+func (r *ScanRun) writePlaylist(output *OutputConfig) error {
+	fileList := filterExtensions(r.FoundFilesPaths, output.Extensions)
+	if output.Where != "" {
+		where, err := output.whereExpression()
+		if err != nil {
+			return err
+		}
+		fileList = r.filterWhere(fileList, where)
+	}
+	switch output.Mode {
+	case ModePerFolder:
+		return r.writeFolderPlaylists(output, fileList)
+	case ModeGroupBy:
+		return r.writeGroupPlaylists(output, fileList)
+	}
+	return r.writePlaylistFile(output, output.Path, fileList)
+}
+
+// writePlaylistFile orders, truncates and writes `fileList` to the playlist at `playlistPath`.
+func (r *ScanRun) writePlaylistFile(output *OutputConfig, playlistPath string, fileList []string) error {
+	if output.History.Path == "" {
+		return r.orderAndWritePlaylistFile(output, playlistPath, fileList)
+	}
+	// The history stays locked from its reading to its update, so that concurrent runs do not pick
+	// their entries without seeing the ones recorded by each other.
+	return withFileLock(output.History.Path, func() error {
+		return r.orderAndWritePlaylistFile(output, playlistPath, fileList)
+	})
+}
+
+func (r *ScanRun) orderAndWritePlaylistFile(output *OutputConfig, playlistPath string, fileList []string) error {
+	if output.RandomizeList && output.History.Path != "" {
+		recent, err := output.History.recentlyPlayed(time.Now())
+		if err != nil {
+			return err
+		}
+		r.verbose("Shuffling the found files, %d of them were played recently", len(recent))
+		shuffleWithHistory(fileList, recent, output.History.Mode)
+	} else if output.RandomizeList {
+		r.verbose("Shuffling the found files")
+		ShuffleSlice(fileList)
+	} else {
+		sortEntries(fileList, output.Sort)
+	}
+	if output.Interleave {
+		r.verbose("Interleaving the entries of the scan roots")
+		fileList = r.interleaveRoots(output, fileList)
+	}
+	if output.RootQuota > 0 || (output.RandomizeList && len(output.RootWeights) > 0) {
+		r.verbose("Selecting the entries across the scan roots")
+		fileList = r.selectAcrossRoots(output, fileList)
+	}
+	selected := fileList
+	if output.Inserts.Source != "" {
+		inserts, err := r.outputInserts(output)
+		if err != nil {
+			return fmt.Errorf("could not read the inserts: %w", err)
+		}
+		r.verbose("Inserting the %d entries of %s", len(inserts), output.Inserts.Source)
+		fileList = r.insertEntries(&output.Inserts, fileList, inserts)
+	}
+
+	foundFilesPathsCount := len(fileList)
+	max := output.MaximumEntries
+	if max < 1 {
+		r.verbose("No maximum entries. Writing all %d files to output.", foundFilesPathsCount)
+		max = foundFilesPathsCount
+	} else if max > foundFilesPathsCount {
+		r.verbose("Limited to %d. Writing all %d found files to output.", max, foundFilesPathsCount)
+		max = foundFilesPathsCount
+	} else {
+		r.verbose("Limited to %d. Writing the first %d found files to output.", max, max)
+	}
+	pinnedCount := len(output.Prepend) + len(output.Append)
+	if output.PinnedInMaximum && output.MaximumEntries > 0 && max > output.MaximumEntries-pinnedCount {
+		max = output.MaximumEntries - pinnedCount
+		if max < 0 {
+			max = 0
+		}
+		r.verbose("Writing %d found files to leave room for the %d pinned entries.", max, pinnedCount)
+	}
+	entries := append(append(slices.Clone(output.Prepend), fileList[:max]...), output.Append...)
+
+	content := new(bytes.Buffer)
+	if err := r.renderPlaylist(content, output, playlistPath, entries); err != nil {
+		return err
+	}
+	if output.ReportChanges && (output.Format == "" || output.Format == FormatM3U || output.Format == FormatExtendedM3U) {
+		r.reportChanges(output, playlistPath, entries)
+	}
+	if err := r.writeFileIfChanged(playlistPath, content.Bytes()); err != nil {
+		return err
+	}
+	if output.History.Path != "" {
+		played := playedEntries(fileList[:max], selected)
+		r.verbose("Recording the %d entries of %s in the history %s", len(played), playlistPath, output.History.Path)
+		return output.History.record(time.Now(), playlistPath, played)
+	}
+	return nil
+}
+
+// writeFileIfChanged writes `content` to `path`, unless the file already has this exact content.
+func (r *ScanRun) writeFileIfChanged(path string, content []byte) error {
+	if existing, readErr := os.ReadFile(path); readErr == nil && bytes.Equal(existing, content) {
+		r.verbose("Playlist %s is already up to date, no change needed", path)
+		return nil
+	}
+
+	r.verbose("Writing playlist to %s", path)
+	return os.WriteFile(path, content, 0644)
+}
+
+// writesRelativePaths indicates if the entries are written relative to the playlist's folder.
+func (o *OutputConfig) writesRelativePaths() bool {
+	return o.RelativePaths || o.Mode == ModePerFolder
+}
+
+// relativePaths returns `paths` expressed relative to `baseDir`. Paths which cannot be made
+// relative (eg: on another volume) are kept as they are.
+func relativePaths(baseDir string, paths []string) []string {
+	relative := make([]string, len(paths))
+	for i, p := range paths {
+		rel, err := filepath.Rel(baseDir, p)
+		if err != nil || isURL(p) {
+			rel = p
+		}
+		relative[i] = rel
+	}
+	return relative
+}
+
+// filterExtensions returns a copy of `paths` only containing the ones with one of the `extensions`
+// (see `matchesExtensions`).
+func filterExtensions(paths []string, extensions []string) []string {
+	if len(extensions) == 0 {
+		return slices.Clone(paths)
+	}
+	extensions = lowerCaseAll(extensions)
+	filtered := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if matchesExtensions(p, extensions) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// matchesExtensions indicates if `p` has one of the lower-cased `extensions`. URLs (eg: streams or
+// the entries of a scanned playlist) always match, as they often have no extension. The scan and
+// the outputs filter with this same rule.
+func matchesExtensions(p string, extensions []string) bool {
+	return isURL(p) || slices.Contains(extensions, strings.ToLower(fileExtension(p)))
+}
+
+// whereExpression parses the `where` expression of the output, nil when there is none.
+func (o *OutputOptions) whereExpression() (*filter.Expression, error) {
+	if o.Where == "" {
+		return nil, nil
+	}
+	where, err := filter.Parse(o.Where)
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression: %w", err)
+	}
+	return where, nil
+}
+
+// filterWhere returns the `paths` matching the `where` expression.
+func (r *ScanRun) filterWhere(paths []string, where *filter.Expression) []string {
+	filtered := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if where.Match(&filter.Entry{Path: p, Tags: r.Metadata[p]}) {
+			filtered = append(filtered, p)
+		}
+	}
+	r.verbose("%d of %d entries match %q", len(filtered), len(paths), where)
+	return filtered
+}
+
+// sortEntries sorts `paths` by path or, with `SortByName`, by file name.
+func sortEntries(paths []string, order string) {
+	if order == SortByName {
+		sort.SliceStable(paths, func(i, j int) bool {
+			return filepath.Base(paths[i]) < filepath.Base(paths[j])
+		})
+	} else {
+		sort.Strings(paths)
+	}
+}
+
+// renderPlaylist writes the playlist of `paths`, located at `playlistPath`, in the output's format.
+func (r *ScanRun) renderPlaylist(w io.Writer, output *OutputConfig, playlistPath string, paths []string) error {
+	switch output.Format {
+	case FormatTemplate:
+		return r.renderTemplate(w, output, playlistPath, paths)
+	case FormatJSONLines, FormatCSV:
+		return r.renderExport(w, output.Format, paths)
+	}
+	locations := paths
+	if output.writesRelativePaths() {
+		locations = relativePaths(filepath.Dir(playlistPath), paths)
+	}
+	if output.Format == FormatExtendedM3U {
+		if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+			return err
+		}
+	}
+	for i, p := range paths {
+		if output.Format == FormatExtendedM3U {
+			if _, err := fmt.Fprintln(w, r.extInf(p)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, locations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extInf returns the `#EXTINF` line of a path, using its tags when they were read.
+func (r *ScanRun) extInf(p string) string {
+	duration := -1
+	title := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	if t := r.Metadata[p]; t != nil {
+		if t.Duration > 0 {
+			duration = int(t.Duration.Seconds())
+		}
+		if t.Title != "" {
+			title = t.Title
+			if t.Artist != "" {
+				title = t.Artist + " - " + t.Title
+			}
+		}
+	}
+	return fmt.Sprintf("#EXTINF:%d,%s", duration, title)
+}
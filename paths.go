@@ -0,0 +1,119 @@
+package m3ugen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandPaths expands `~` and the environment variables (`$VAR` or `${VAR}`) of the paths of the
+// configuration, resolves the relative ones against `baseDir` (eg: the folder of the configuration
+// file; kept relative when empty), then expands the globs (see `ExpandGlobs`).
+func (c *Config) ExpandPaths(baseDir string) error {
+	c.OutputPath = ExpandPath(c.OutputPath, baseDir)
+	for i := range c.ScanFolders {
+		c.ScanFolders[i] = ExpandPath(c.ScanFolders[i], baseDir)
+	}
+	c.OutputOptions.expandPaths(baseDir)
+	for _, output := range c.Outputs {
+		output.Path = ExpandPath(output.Path, baseDir)
+		output.OutputOptions.expandPaths(baseDir)
+	}
+	return c.ExpandGlobs()
+}
+
+// ExpandGlobs replaces the `scan` globs (eg: `/mnt/disk*/Music`) with the matching paths, leaving the
+// other paths untouched. A glob matching nothing is kept as is, to be reported by `Validate`. The keys
+// of `root_weights` are expanded the same way, each matching root getting the weight of the glob.
+func (c *Config) ExpandGlobs() error {
+	var scanFolders []string
+	for _, folder := range c.ScanFolders {
+		matches, err := expandGlob(folder)
+		if err != nil {
+			return err
+		}
+		scanFolders = append(scanFolders, matches...)
+	}
+	c.ScanFolders = scanFolders
+	if err := c.OutputOptions.expandRootWeights(); err != nil {
+		return err
+	}
+	for _, output := range c.Outputs {
+		if err := output.OutputOptions.expandRootWeights(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *OutputOptions) expandPaths(baseDir string) {
+	o.Template = ExpandPath(o.Template, baseDir)
+	o.Directory = ExpandPath(o.Directory, baseDir)
+	o.History.Path = ExpandPath(o.History.Path, baseDir)
+	o.Inserts.Source = ExpandPath(o.Inserts.Source, baseDir)
+	for i := range o.Prepend {
+		o.Prepend[i] = ExpandPath(o.Prepend[i], baseDir)
+	}
+	for i := range o.Append {
+		o.Append[i] = ExpandPath(o.Append[i], baseDir)
+	}
+	if len(o.RootWeights) > 0 { // keyed by scan folder, expanded the same way
+		rootWeights := make(map[string]float64, len(o.RootWeights))
+		for root, weight := range o.RootWeights {
+			rootWeights[ExpandPath(root, baseDir)] = weight
+		}
+		o.RootWeights = rootWeights
+	}
+}
+
+func (o *OutputOptions) expandRootWeights() error {
+	if len(o.RootWeights) == 0 {
+		return nil
+	}
+	rootWeights := make(map[string]float64, len(o.RootWeights))
+	for root, weight := range o.RootWeights {
+		matches, err := expandGlob(root)
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			rootWeights[match] = weight
+		}
+	}
+	o.RootWeights = rootWeights
+	return nil
+}
+
+// expandGlob returns the paths matching the glob `p`, or `p` itself when it is not a glob or
+// matches nothing.
+func expandGlob(p string) ([]string, error) {
+	if !strings.ContainsAny(p, "*?[") {
+		return []string{p}, nil
+	}
+	matches, err := filepath.Glob(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return []string{p}, nil
+	}
+	return matches, nil
+}
+
+// ExpandPath expands `~` and the environment variables of a path, and resolves it against `baseDir`
+// when it is relative (kept relative when `baseDir` is empty). URLs are left untouched.
+func ExpandPath(p string, baseDir string) string {
+	if p == "" || isURL(p) {
+		return p
+	}
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if baseDir != "" && !filepath.IsAbs(p) {
+		p = filepath.Join(baseDir, p)
+	}
+	return p
+}
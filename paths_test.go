@@ -0,0 +1,44 @@
+package m3ugen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, disk := range []string{"disk1", "disk2", "other"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, disk, "Music"), os.ModePerm))
+	}
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	t.Setenv("M3UGEN_TEST_DIR", dir)
+
+	config := NewDefaultConfig()
+	config.OutputPath = "playlists/out.m3u"
+	config.ScanFolders = []string{"$M3UGEN_TEST_DIR/disk*/Music", "~/Music", filepath.Join(dir, "missing*")}
+	config.RootWeights = map[string]float64{"~/Music": 1, "$M3UGEN_TEST_DIR/disk*/Music": 2}
+	config.Append = []string{"http://radio.example/sign-off.mp3"}
+	config.Outputs = []*OutputConfig{{Path: "${M3UGEN_TEST_DIR}/all.m3u", OutputOptions: OutputOptions{History: HistoryOptions{Path: "history.jsonl"}}}}
+	require.NoError(t, config.ExpandPaths("/etc/m3ugen"))
+
+	assert.Equal(t, filepath.Join("/etc/m3ugen", "playlists", "out.m3u"), config.OutputPath)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "disk1", "Music"),
+		filepath.Join(dir, "disk2", "Music"),
+		filepath.Join(home, "Music"),
+		filepath.Join(dir, "missing*"),
+	}, config.ScanFolders)
+	assert.Equal(t, map[string]float64{
+		filepath.Join(home, "Music"):         1,
+		filepath.Join(dir, "disk1", "Music"): 2,
+		filepath.Join(dir, "disk2", "Music"): 2,
+	}, config.RootWeights, "globs match the scan folders")
+	assert.Equal(t, []string{"http://radio.example/sign-off.mp3"}, config.Append)
+	assert.Equal(t, filepath.Join(dir, "all.m3u"), config.Outputs[0].Path)
+	assert.Equal(t, filepath.Join("/etc/m3ugen", "history.jsonl"), config.Outputs[0].History.Path)
+}
@@ -0,0 +1,484 @@
+// Package filter parses and evaluates the `where` expressions selecting playlist entries, eg:
+//
+//	genre in ["Jazz", "Blues"] and year >= 1960 and duration < 10m and not path ~ "live"
+//
+// Expressions combine comparisons with `and`, `or`, `not` and parentheses. A comparison is a
+// field, an operator and a literal of the field's type:
+//
+//   - text fields (`path`, `name`, `extension`, `title`, `artist`, `album_artist`, `album` and
+//     `genre`) support `==`, `!=` and `in` (case-insensitive), and `~` (regular expression);
+//   - `year` is a number and `duration` a duration (eg: `90s`, `3m30s`, `1h`); both support
+//     `==`, `!=`, `<`, `<=`, `>`, `>=` and `in`.
+//
+// Unknown values (eg: entries without tags) are empty texts, and never match comparisons on
+// numbers and durations.
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/adeynack/m3ugen/pkg/tags"
+)
+
+type valueType int
+
+const (
+	typeText valueType = iota
+	typeNumber
+	typeDuration
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeNumber:
+		return "number"
+	case typeDuration:
+		return "duration"
+	default:
+		return "text"
+	}
+}
+
+// fields are the fields an expression can use, with their type.
+var fields = map[string]valueType{
+	"path":         typeText,
+	"name":         typeText,
+	"extension":    typeText,
+	"title":        typeText,
+	"artist":       typeText,
+	"album_artist": typeText,
+	"album":        typeText,
+	"genre":        typeText,
+	"year":         typeNumber,
+	"duration":     typeDuration,
+}
+
+// Entry is what an expression is evaluated against.
+type Entry struct {
+	// Path (or URL) of the entry.
+	Path string
+	// Tags of the entry. Nil when unknown.
+	Tags *tags.Tags
+}
+
+// text returns the value of a text field.
+func (e *Entry) text(field string) string {
+	t := e.Tags
+	if t == nil {
+		t = new(tags.Tags)
+	}
+	switch field {
+	case "path":
+		return e.Path
+	case "name":
+		return filepath.Base(e.Path)
+	case "extension":
+		return strings.TrimPrefix(filepath.Ext(e.Path), ".")
+	case "title":
+		return t.Title
+	case "artist":
+		return t.Artist
+	case "album_artist":
+		return t.AlbumArtist
+	case "album":
+		return t.Album
+	default:
+		return t.Genre
+	}
+}
+
+// number returns the value of a number or duration field, and false when it is unknown.
+func (e *Entry) number(field string) (int64, bool) {
+	if e.Tags == nil {
+		return 0, false
+	}
+	if field == "year" {
+		return int64(e.Tags.Year), e.Tags.Year > 0
+	}
+	return int64(e.Tags.Duration), e.Tags.Duration > 0
+}
+
+// SyntaxError is an invalid expression.
+type SyntaxError struct {
+	// Column (starting at 1) of the character of the expression where the error is.
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+// Expression is a parsed and type-checked expression.
+type Expression struct {
+	source string
+	root   node
+}
+
+// String returns the source of the expression.
+func (x *Expression) String() string {
+	return x.source
+}
+
+// Match evaluates the expression against an entry.
+func (x *Expression) Match(e *Entry) bool {
+	return x.root.match(e)
+}
+
+type node interface {
+	match(e *Entry) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n *andNode) match(e *Entry) bool { return n.left.match(e) && n.right.match(e) }
+
+type orNode struct{ left, right node }
+
+func (n *orNode) match(e *Entry) bool { return n.left.match(e) || n.right.match(e) }
+
+type notNode struct{ operand node }
+
+func (n *notNode) match(e *Entry) bool { return !n.operand.match(e) }
+
+type comparisonNode struct {
+	field    string
+	operator string
+	texts    []string
+	numbers  []int64
+	regexp   *regexp.Regexp
+}
+
+func (n *comparisonNode) match(e *Entry) bool {
+	if fields[n.field] == typeText {
+		value := e.text(n.field)
+		switch n.operator {
+		case "~":
+			return n.regexp.MatchString(value)
+		case "!=":
+			return !strings.EqualFold(value, n.texts[0])
+		default: // `==` and `in`
+			return slices.ContainsFunc(n.texts, func(t string) bool { return strings.EqualFold(value, t) })
+		}
+	}
+
+	value, known := e.number(n.field)
+	if !known {
+		return false
+	}
+	switch n.operator {
+	case "!=":
+		return value != n.numbers[0]
+	case "<":
+		return value < n.numbers[0]
+	case "<=":
+		return value <= n.numbers[0]
+	case ">":
+		return value > n.numbers[0]
+	case ">=":
+		return value >= n.numbers[0]
+	default: // `==` and `in`
+		return slices.Contains(n.numbers, value)
+	}
+}
+
+// Parse parses and type-checks an expression.
+func Parse(source string) (*Expression, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &SyntaxError{Column: t.column, Message: fmt.Sprintf("unexpected %s", t)}
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenText
+	tokenNumber
+	tokenDuration
+	tokenOperator
+	tokenPunctuation
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	column int
+	// Value of number and duration literals, and unquoted value of text literals.
+	number int64
+	value  string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenText:
+		return t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		column := i + 1
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '_' || unicode.IsLetter(r):
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, text: string(runes[start:i]), column: column})
+		case unicode.IsDigit(r):
+			for i < len(runes) && (runes[i] == '.' || unicode.IsDigit(runes[i]) || unicode.IsLetter(runes[i])) {
+				i++
+			}
+			literal := string(runes[start:i])
+			t := token{kind: tokenNumber, text: literal, column: column}
+			if strings.IndexFunc(literal, unicode.IsLetter) >= 0 {
+				d, err := time.ParseDuration(literal)
+				if err != nil {
+					return nil, &SyntaxError{Column: column, Message: fmt.Sprintf("invalid duration %q", literal)}
+				}
+				t.kind, t.number = tokenDuration, int64(d)
+			} else {
+				n, err := strconv.ParseInt(literal, 10, 64)
+				if err != nil {
+					return nil, &SyntaxError{Column: column, Message: fmt.Sprintf("invalid number %q", literal)}
+				}
+				t.number = n
+			}
+			tokens = append(tokens, t)
+		case r == '"':
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(runes) {
+				return nil, &SyntaxError{Column: column, Message: "unterminated text"}
+			}
+			i++
+			literal := string(runes[start:i])
+			value, err := strconv.Unquote(literal)
+			if err != nil {
+				return nil, &SyntaxError{Column: column, Message: fmt.Sprintf("invalid text %s", literal)}
+			}
+			tokens = append(tokens, token{kind: tokenText, text: literal, value: value, column: column})
+		case strings.ContainsRune("=!<>", r):
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			operator := string(runes[start:i])
+			if operator == "=" || operator == "!" {
+				return nil, &SyntaxError{Column: column, Message: fmt.Sprintf("unknown operator %q", operator)}
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, column: column})
+		case r == '~':
+			i++
+			tokens = append(tokens, token{kind: tokenOperator, text: "~", column: column})
+		case strings.ContainsRune("()[],", r):
+			i++
+			tokens = append(tokens, token{kind: tokenPunctuation, text: string(r), column: column})
+		default:
+			return nil, &SyntaxError{Column: column, Message: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, column: len(runes) + 1}), nil
+}
+
+type parser struct {
+	tokens   []token
+	position int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != tokenEOF {
+		p.position++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is the identifier `word`.
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokenIdentifier && t.text == word {
+		p.position++
+		return true
+	}
+	return false
+}
+
+// punctuation consumes the next token if it is `text`.
+func (p *parser) punctuation(text string) bool {
+	if t := p.peek(); t.kind == tokenPunctuation && t.text == text {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	if open := p.peek(); p.punctuation("(") {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.punctuation(")") {
+			return nil, &SyntaxError{Column: p.peek().column, Message: fmt.Sprintf("missing \")\" closing the \"(\" of column %d", open.column)}
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	field := p.next()
+	if field.kind != tokenIdentifier {
+		return nil, &SyntaxError{Column: field.column, Message: fmt.Sprintf("expected a field, found %s", field)}
+	}
+	fieldType, ok := fields[field.text]
+	if !ok {
+		return nil, &SyntaxError{Column: field.column, Message: fmt.Sprintf("unknown field %q", field.text)}
+	}
+	n := &comparisonNode{field: field.text}
+
+	operator := p.next()
+	switch {
+	case operator.kind == tokenIdentifier && operator.text == "in":
+		n.operator = "in"
+		if err := p.parseList(n, fieldType); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case operator.kind != tokenOperator:
+		return nil, &SyntaxError{Column: operator.column, Message: fmt.Sprintf("expected an operator after %q, found %s", field.text, operator)}
+	}
+	n.operator = operator.text
+	switch {
+	case fieldType == typeText && !slices.Contains([]string{"==", "!=", "~"}, n.operator):
+		return nil, &SyntaxError{Column: operator.column, Message: fmt.Sprintf("operator %q cannot be used on text field %q", n.operator, field.text)}
+	case fieldType != typeText && n.operator == "~":
+		return nil, &SyntaxError{Column: operator.column, Message: fmt.Sprintf("operator \"~\" cannot be used on %s field %q", fieldType, field.text)}
+	}
+
+	literal := p.peek()
+	if err := p.parseLiteral(n, fieldType); err != nil {
+		return nil, err
+	}
+	if n.operator == "~" {
+		re, err := regexp.Compile(n.texts[0])
+		if err != nil {
+			return nil, &SyntaxError{Column: literal.column, Message: fmt.Sprintf("invalid regular expression: %s", err)}
+		}
+		n.regexp = re
+	}
+	return n, nil
+}
+
+func (p *parser) parseList(n *comparisonNode, fieldType valueType) error {
+	if t := p.peek(); !p.punctuation("[") {
+		return &SyntaxError{Column: t.column, Message: fmt.Sprintf("expected \"[\" after \"in\", found %s", t)}
+	}
+	for {
+		if err := p.parseLiteral(n, fieldType); err != nil {
+			return err
+		}
+		if p.punctuation("]") {
+			return nil
+		}
+		if t := p.peek(); !p.punctuation(",") {
+			return &SyntaxError{Column: t.column, Message: fmt.Sprintf("expected \",\" or \"]\", found %s", t)}
+		}
+	}
+}
+
+func (p *parser) parseLiteral(n *comparisonNode, fieldType valueType) error {
+	literal := p.next()
+	var literalType valueType
+	switch literal.kind {
+	case tokenText:
+		literalType = typeText
+	case tokenNumber:
+		literalType = typeNumber
+	case tokenDuration:
+		literalType = typeDuration
+	default:
+		return &SyntaxError{Column: literal.column, Message: fmt.Sprintf("expected a %s, found %s", fieldType, literal)}
+	}
+	if literalType != fieldType {
+		return &SyntaxError{Column: literal.column, Message: fmt.Sprintf("field %q is a %s, found the %s %s", n.field, fieldType, literalType, literal.text)}
+	}
+	if fieldType == typeText {
+		n.texts = append(n.texts, literal.value)
+	} else {
+		n.numbers = append(n.numbers, literal.number)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adeynack/m3ugen/pkg/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Match(t *testing.T) {
+	soWhat := &Entry{Path: "/music/Kind of Blue/So What.mp3", Tags: &tags.Tags{Genre: "jazz", Year: 1959, Duration: 562 * time.Second}}
+	liveBlues := &Entry{Path: "/music/live/Blues.flac", Tags: &tags.Tags{Genre: "Blues", Year: 1971, Duration: 5 * time.Minute}}
+	untagged := &Entry{Path: "/music/untagged.mp3"}
+
+	for source, expected := range map[string][]bool{
+		`genre in ["Jazz", "Blues"] and year >= 1960 and duration < 10m and not path ~ "live"`: {false, false, false},
+		`genre in ["Jazz", "Blues"] and (year < 1960 or path ~ "live")`:                        {true, true, false},
+		`genre == "JAZZ"`:                      {true, false, false},
+		`genre != "jazz"`:                      {false, true, true},
+		`year == 1959 or year in [1971, 1972]`: {true, true, false},
+		`not year > 1960`:                      {true, false, true},
+		`duration >= 9m22s`:                    {true, false, false},
+		`extension == "flac"`:                  {false, true, false},
+		`name ~ "^So "`:                        {true, false, false},
+		`not not (title == "")`:                {true, true, true},
+	} {
+		x, err := Parse(source)
+		require.NoError(t, err, source)
+		assert.Equal(t, expected, []bool{x.Match(soWhat), x.Match(liveBlues), x.Match(untagged)}, source)
+	}
+}
+
+func Test_ParseErrors(t *testing.T) {
+	for source, expected := range map[string]string{
+		`genre == "Jazz" and yaer >= 1960`: `column 21: unknown field "yaer"`,
+		`year >= "1960"`:                   `column 9: field "year" is a number, found the text "1960"`,
+		`duration < 600`:                   `column 12: field "duration" is a duration, found the number 600`,
+		`genre < "Jazz"`:                   `column 7: operator "<" cannot be used on text field "genre"`,
+		`year ~ "19"`:                      `column 6: operator "~" cannot be used on number field "year"`,
+		`path ~ "(live"`:                   "column 8: invalid regular expression: error parsing regexp: missing closing ): `(live`",
+		`genre in ["Jazz" "Blues"]`:        `column 18: expected "," or "]", found "Blues"`,
+		`(year > 1960`:                     `column 13: missing ")" closing the "(" of column 1`,
+		`year > 1960 year < 1970`:          `column 13: unexpected "year"`,
+		`genre = "Jazz"`:                   `column 7: unknown operator "="`,
+		`title == "Jazz`:                   `column 10: unterminated text`,
+		`duration < 10 minutes`:            `column 12: field "duration" is a duration, found the number 10`,
+		`duration < 10x`:                   `column 12: invalid duration "10x"`,
+		`genre`:                            `column 6: expected an operator after "genre", found end of expression`,
+		``:                                 `column 1: expected a field, found end of expression`,
+	} {
+		_, err := Parse(source)
+		if assert.Error(t, err, source) {
+			assert.Equal(t, expected, err.Error(), source)
+			var syntaxError *SyntaxError
+			assert.ErrorAs(t, err, &syntaxError, source)
+		}
+	}
+}
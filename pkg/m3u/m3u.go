@@ -0,0 +1,224 @@
+// Package m3u reads and writes plain and extended M3U (and M3U8) playlists.
+package m3u
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	utf8BOM = "\uFEFF"
+
+	directiveHeader     = "#EXTM3U"
+	directiveInfo       = "#EXTINF:"
+	directiveGroup      = "#EXTGRP:"
+	directiveVLCOption  = "#EXTVLCOPT:"
+	extendedInfoUnknown = -1
+)
+
+var (
+	regexURLScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+	regexAttribute = regexp.MustCompile(`([A-Za-z0-9_-]+)="([^"]*)"`)
+)
+
+// Playlist is the content of an M3U file.
+type Playlist struct {
+	// Extended is true when the playlist starts with the `#EXTM3U` header.
+	Extended bool
+	// Entries of the playlist, in order.
+	Entries []*Entry
+}
+
+// Entry is an item of a playlist, with the information of the directives preceding it.
+type Entry struct {
+	// Location of the media, as written in the playlist: a path (absolute or relative to
+	// the playlist) or a URL.
+	Location string
+	// Line is the number (starting at 1) of the line holding the location.
+	Line int
+	// Duration of the media, from `#EXTINF`. Negative when unknown (eg: live streams).
+	Duration time.Duration
+	// Title of the media, from `#EXTINF`.
+	Title string
+	// Attributes of the `#EXTINF` directive (eg: `tvg-logo="..."`).
+	Attributes map[string]string
+	// Group of the media, from `#EXTGRP` or the `group-title` attribute of `#EXTINF`.
+	Group string
+	// VLCOptions are the values of the `#EXTVLCOPT` directives (eg: `network-caching=1000`).
+	VLCOptions []string
+}
+
+// ParseError is returned when a playlist contains a malformed directive.
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// IsURL indicates if the location of the entry is a URL (eg: `http://...`, `file:///...`).
+func (e *Entry) IsURL() bool {
+	return IsURL(e.Location)
+}
+
+// IsURL indicates if a location is a URL (eg: `http://...`, `file:///...`) rather than a path.
+func IsURL(location string) bool {
+	return regexURLScheme.MatchString(location)
+}
+
+// Path returns the local path of the entry, resolving relative locations against `baseDir`
+// (usually the playlist's folder) and converting `file://` URLs. It returns false for other URLs.
+func (e *Entry) Path(baseDir string) (string, bool) {
+	if e.IsURL() {
+		u, err := url.Parse(e.Location)
+		if err != nil || u.Scheme != "file" {
+			return "", false
+		}
+		return filepath.FromSlash(u.Path), true
+	}
+	p := filepath.FromSlash(e.Location)
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(baseDir, p)
+	}
+	return filepath.Clean(p), true
+}
+
+// ParseFile reads the playlist at `path`.
+func ParseFile(path string) (*Playlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	playlist, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return playlist, nil
+}
+
+// Parse reads a playlist. Both LF and CRLF line endings are supported, as well as a leading
+// UTF-8 byte order mark. Unknown directives and comments are ignored.
+func Parse(r io.Reader) (*Playlist, error) {
+	playlist := &Playlist{}
+	reader := bufio.NewReader(r)
+	next := &Entry{Duration: extendedInfoUnknown}
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		line = strings.TrimSpace(strings.TrimRight(line, "\r\n"))
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, directiveHeader) && lineNumber == 1:
+			playlist.Extended = true
+		case strings.HasPrefix(line, directiveInfo):
+			if err := parseInfo(next, line[len(directiveInfo):]); err != nil {
+				return nil, &ParseError{Line: lineNumber, Message: err.Error()}
+			}
+		case strings.HasPrefix(line, directiveGroup):
+			next.Group = strings.TrimSpace(line[len(directiveGroup):])
+		case strings.HasPrefix(line, directiveVLCOption):
+			next.VLCOptions = append(next.VLCOptions, strings.TrimSpace(line[len(directiveVLCOption):]))
+		case strings.HasPrefix(line, "#"):
+			// Comment or unsupported directive.
+		default:
+			next.Location = line
+			next.Line = lineNumber
+			playlist.Entries = append(playlist.Entries, next)
+			next = &Entry{Duration: extendedInfoUnknown}
+		}
+
+		if readErr == io.EOF {
+			return playlist, nil
+		}
+	}
+}
+
+// parseInfo parses the value of an `#EXTINF` directive: `<duration> [attributes],<title>`.
+func parseInfo(entry *Entry, value string) error {
+	head, title := value, ""
+	inQuotes := false
+	for i, c := range value {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ',' && !inQuotes {
+			head, title = value[:i], value[i+1:]
+			break
+		}
+	}
+	head = strings.TrimSpace(head)
+	durationText, attributes, _ := strings.Cut(head, " ")
+	seconds, err := strconv.ParseFloat(durationText, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return fmt.Errorf("invalid #EXTINF duration %q", durationText)
+	}
+	if seconds < 0 {
+		entry.Duration = extendedInfoUnknown
+	} else {
+		entry.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	entry.Title = strings.TrimSpace(title)
+	for _, match := range regexAttribute.FindAllStringSubmatch(attributes, -1) {
+		if entry.Attributes == nil {
+			entry.Attributes = make(map[string]string)
+		}
+		entry.Attributes[match[1]] = match[2]
+	}
+	if group, ok := entry.Attributes["group-title"]; ok && entry.Group == "" {
+		entry.Group = group
+	}
+	return nil
+}
+
+// Write writes an extended M3U playlist. The `#EXTINF` directive of an entry is only written
+// when its title or duration is known.
+func Write(w io.Writer, playlist *Playlist) error {
+	bw := bufio.NewWriter(w)
+	if playlist.Extended {
+		fmt.Fprintln(bw, directiveHeader)
+	}
+	for _, entry := range playlist.Entries {
+		if playlist.Extended && (entry.Title != "" || entry.Duration >= 0 || len(entry.Attributes) > 0) {
+			duration := int64(extendedInfoUnknown)
+			if entry.Duration >= 0 {
+				duration = int64(entry.Duration.Round(time.Second) / time.Second)
+			}
+			fmt.Fprintf(bw, "%s%d", directiveInfo, duration)
+			names := make([]string, 0, len(entry.Attributes))
+			for name := range entry.Attributes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(bw, " %s=%q", name, entry.Attributes[name])
+			}
+			fmt.Fprintf(bw, ",%s\n", entry.Title)
+		}
+		if playlist.Extended && entry.Group != "" && entry.Attributes["group-title"] != entry.Group {
+			fmt.Fprintf(bw, "%s%s\n", directiveGroup, entry.Group)
+		}
+		for _, option := range entry.VLCOptions {
+			fmt.Fprintf(bw, "%s%s\n", directiveVLCOption, option)
+		}
+		fmt.Fprintln(bw, entry.Location)
+	}
+	return bw.Flush()
+}
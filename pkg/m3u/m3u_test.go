@@ -0,0 +1,108 @@
+package m3u
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePlain(t *testing.T) {
+	playlist, err := Parse(strings.NewReader("music/a.mp3\n\n# a comment\n/abs/b.mp3\nhttp://radio.example/stream"))
+	require.NoError(t, err)
+	assert.False(t, playlist.Extended)
+	if assert.Len(t, playlist.Entries, 3) {
+		assert.Equal(t, &Entry{Location: "music/a.mp3", Line: 1, Duration: -1}, playlist.Entries[0])
+		assert.Equal(t, &Entry{Location: "/abs/b.mp3", Line: 4, Duration: -1}, playlist.Entries[1])
+		assert.Equal(t, &Entry{Location: "http://radio.example/stream", Line: 5, Duration: -1}, playlist.Entries[2])
+	}
+}
+
+func Test_ParseExtended(t *testing.T) {
+	content := "\uFEFF#EXTM3U\r\n" +
+		"#EXTINF:123,Miles Davis - So What\r\n" +
+		"#EXTGRP:Jazz\r\n" +
+		"jazz/so-what.mp3\r\n" +
+		"#EXTINF:-1 tvg-id=\"radio.1\" group-title=\"Radios, live\",Radio One\r\n" +
+		"#EXTVLCOPT:network-caching=1000\r\n" +
+		"#EXTVLCOPT:http-user-agent=m3ugen\r\n" +
+		"https://radio.example/one\r\n"
+	playlist, err := Parse(strings.NewReader(content))
+	require.NoError(t, err)
+	assert.True(t, playlist.Extended)
+	if assert.Len(t, playlist.Entries, 2) {
+		assert.Equal(t, &Entry{
+			Location: "jazz/so-what.mp3",
+			Line:     4,
+			Duration: 123 * time.Second,
+			Title:    "Miles Davis - So What",
+			Group:    "Jazz",
+		}, playlist.Entries[0])
+		assert.Equal(t, &Entry{
+			Location:   "https://radio.example/one",
+			Line:       8,
+			Duration:   -1,
+			Title:      "Radio One",
+			Attributes: map[string]string{"tvg-id": "radio.1", "group-title": "Radios, live"},
+			Group:      "Radios, live",
+			VLCOptions: []string{"network-caching=1000", "http-user-agent=m3ugen"},
+		}, playlist.Entries[1])
+	}
+}
+
+func Test_ParseInvalidInfo(t *testing.T) {
+	_, err := Parse(strings.NewReader("#EXTM3U\n#EXTINF:abc,Title\nfile.mp3\n"))
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, 2, parseErr.Line)
+		assert.Equal(t, `line 2: invalid #EXTINF duration "abc"`, parseErr.Error())
+	}
+}
+
+func Test_EntryPath(t *testing.T) {
+	base := filepath.FromSlash("/music/playlists")
+
+	p, ok := (&Entry{Location: "../jazz/a.mp3"}).Path(base)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.FromSlash("/music/jazz/a.mp3"), p)
+
+	p, ok = (&Entry{Location: "/abs/b.mp3"}).Path(base)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.FromSlash("/abs/b.mp3"), p)
+
+	p, ok = (&Entry{Location: "file:///music/c%20d.mp3"}).Path(base)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.FromSlash("/music/c d.mp3"), p)
+
+	_, ok = (&Entry{Location: "http://radio.example/stream"}).Path(base)
+	assert.False(t, ok)
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("#EXTM3U\n#EXTINF:10,Title\nfile.mp3\n")
+	f.Add("\uFEFF#EXTM3U\r\n#EXTINF:-1 a=\"b,c\",T\r\n#EXTGRP:G\r\n#EXTVLCOPT:x=y\r\nhttp://x/y\r\n")
+	f.Add("plain.mp3\n\n#comment\n")
+	f.Add("#EXTINF:\n#EXTINF:1e400,x\n")
+	f.Fuzz(func(t *testing.T, content string) {
+		playlist, err := Parse(strings.NewReader(content))
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Line < 1 {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return
+		}
+		previousLine := 0
+		for _, entry := range playlist.Entries {
+			if entry.Location == "" || entry.Line <= previousLine {
+				t.Fatalf("invalid entry %+v after line %d", entry, previousLine)
+			}
+			previousLine = entry.Line
+			entry.Path("base")
+		}
+	})
+}
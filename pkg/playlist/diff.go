@@ -0,0 +1,123 @@
+package playlist
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Diff is the difference between two versions of a playlist.
+type Diff struct {
+	// Added are the entries only present in the new version.
+	Added []*DiffEntry `json:"added"`
+	// Removed are the entries only present in the old version.
+	Removed []*DiffEntry `json:"removed"`
+	// Moved are the entries present in both versions, but which order changed relative
+	// to the other entries.
+	Moved []*DiffEntry `json:"moved"`
+}
+
+// DiffEntry is an entry of a `Diff`, with its positions (starting at 1) in both versions of the
+// playlist. A position is 0 when the entry is absent from that version.
+type DiffEntry struct {
+	Location    string `json:"location"`
+	OldPosition int    `json:"old_position,omitempty"`
+	NewPosition int    `json:"new_position,omitempty"`
+}
+
+// Empty indicates if both versions have the same entries, in the same order.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Moved) == 0
+}
+
+// Compare returns the difference between two versions of a playlist, comparing their entries by `Key`.
+// Duplicated entries are only considered at their first position.
+// Entries are considered moved when they are not part of the longest sequence of entries kept in the same order.
+func Compare(oldEntries []*Entry, newEntries []*Entry) *Diff {
+	oldEntries, newEntries = Union(oldEntries), Union(newEntries)
+	diff := &Diff{Added: []*DiffEntry{}, Removed: []*DiffEntry{}, Moved: []*DiffEntry{}}
+
+	oldPositions := make(map[string]int, len(oldEntries))
+	for i, e := range oldEntries {
+		oldPositions[Key(e)] = i + 1
+	}
+	newPositions := make(map[string]int, len(newEntries))
+	for i, e := range newEntries {
+		newPositions[Key(e)] = i + 1
+	}
+
+	for i, e := range oldEntries {
+		if newPositions[Key(e)] == 0 {
+			diff.Removed = append(diff.Removed, &DiffEntry{Location: e.Location, OldPosition: i + 1})
+		}
+	}
+	var common []*DiffEntry
+	for i, e := range newEntries {
+		oldPosition := oldPositions[Key(e)]
+		if oldPosition == 0 {
+			diff.Added = append(diff.Added, &DiffEntry{Location: e.Location, NewPosition: i + 1})
+		} else {
+			common = append(common, &DiffEntry{Location: e.Location, OldPosition: oldPosition, NewPosition: i + 1})
+		}
+	}
+
+	kept := longestIncreasingOldPositions(common)
+	for i, e := range common {
+		if !kept[i] {
+			diff.Moved = append(diff.Moved, e)
+		}
+	}
+	return diff
+}
+
+// longestIncreasingOldPositions returns the indexes of the longest subsequence of `entries`
+// (ordered by new position) which old positions are increasing.
+func longestIncreasingOldPositions(entries []*DiffEntry) map[int]bool {
+	tails := []int{}                      // index of the entry ending the best subsequence of each length
+	previous := make([]int, len(entries)) // index of the previous entry in the subsequence
+	for i, e := range entries {
+		length := sort.Search(len(tails), func(l int) bool {
+			return entries[tails[l]].OldPosition >= e.OldPosition
+		})
+		previous[i] = -1
+		if length > 0 {
+			previous[i] = tails[length-1]
+		}
+		if length == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[length] = i
+		}
+	}
+	kept := make(map[int]bool, len(tails))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			kept[i] = true
+		}
+	}
+	return kept
+}
+
+// WriteText writes the difference in a unified-diff like format: removed entries prefixed with `-`,
+// added ones with `+` and moved ones with `~`.
+func (d *Diff) WriteText(w io.Writer, oldName string, newName string) error {
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName); err != nil {
+		return err
+	}
+	for _, e := range d.Removed {
+		if _, err := fmt.Fprintf(w, "-%s (was #%d)\n", e.Location, e.OldPosition); err != nil {
+			return err
+		}
+	}
+	for _, e := range d.Added {
+		if _, err := fmt.Fprintf(w, "+%s (now #%d)\n", e.Location, e.NewPosition); err != nil {
+			return err
+		}
+	}
+	for _, e := range d.Moved {
+		if _, err := fmt.Fprintf(w, "~%s (#%d -> #%d)\n", e.Location, e.OldPosition, e.NewPosition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package playlist
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Compare(t *testing.T) {
+	oldEntries := entries("/a", "/b", "/c", "/d", "/e")
+	newEntries := entries("/a", "/d", "/b", "/c", "/f", "/a")
+
+	diff := Compare(oldEntries, newEntries)
+	assert.Equal(t, &Diff{
+		Added:   []*DiffEntry{{Location: "/f", NewPosition: 5}},
+		Removed: []*DiffEntry{{Location: "/e", OldPosition: 5}},
+		Moved:   []*DiffEntry{{Location: "/d", OldPosition: 4, NewPosition: 2}},
+	}, diff)
+	assert.False(t, diff.Empty())
+
+	text := new(strings.Builder)
+	assert.NoError(t, diff.WriteText(text, "old.m3u", "new.m3u"))
+	assert.Equal(t, "--- old.m3u\n+++ new.m3u\n-/e (was #5)\n+/f (now #5)\n~/d (#4 -> #2)\n", text.String())
+
+	assert.True(t, Compare(oldEntries, entries("/a", "/b", "/c", "/d", "/e")).Empty())
+}
@@ -0,0 +1,195 @@
+// Package playlist is a common model of playlist entries, read from and written to any of the
+// supported formats: M3U (and M3U8), PLS and XSPF.
+package playlist
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adeynack/m3ugen/pkg/m3u"
+	"github.com/adeynack/m3ugen/pkg/pls"
+	"github.com/adeynack/m3ugen/pkg/xspf"
+)
+
+const (
+	// FormatM3U is the (extended) M3U format, also used for M3U8 files.
+	FormatM3U = "m3u"
+	// FormatPLS is the PLS format.
+	FormatPLS = "pls"
+	// FormatXSPF is the XSPF format.
+	FormatXSPF = "xspf"
+)
+
+// Entry is an item of a playlist.
+type Entry struct {
+	// Location of the media: an absolute path or a URL.
+	Location string
+	// Title of the media. Empty when unknown.
+	Title string
+	// Duration of the media. Negative when unknown (eg: live streams).
+	Duration time.Duration
+	// Line is the number (starting at 1) of the line of the entry in M3U and PLS playlists, and
+	// its position in XSPF playlists. Only set when reading, it is ignored when writing.
+	Line int
+}
+
+// IsURL indicates if the location of the entry is a URL rather than a path.
+func (e *Entry) IsURL() bool {
+	return m3u.IsURL(e.Location)
+}
+
+// FormatOf returns the format of a playlist file, from its extension.
+func FormatOf(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8":
+		return FormatM3U, nil
+	case ".pls":
+		return FormatPLS, nil
+	case ".xspf":
+		return FormatXSPF, nil
+	default:
+		return "", fmt.Errorf("unsupported playlist format: %q", path)
+	}
+}
+
+// Read reads the playlist at `path`, in the format matching its extension. Relative locations
+// are resolved against the playlist's folder, and `file://` URLs are converted to paths.
+func Read(path string) ([]*Entry, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	baseDir := filepath.Dir(path)
+	var entries []*Entry
+	switch format {
+	case FormatPLS:
+		plsEntries, err := pls.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range plsEntries {
+			entries = append(entries, &Entry{Location: resolve(baseDir, e.Location), Title: e.Title, Duration: e.Duration, Line: e.Line})
+		}
+	case FormatXSPF:
+		playlist, err := xspf.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for i, track := range playlist.Tracks {
+			location := track.Location
+			if !m3u.IsURL(location) {
+				if unescaped, err := url.PathUnescape(location); err == nil {
+					location = unescaped // relative URI
+				}
+			}
+			entries = append(entries, &Entry{Location: resolve(baseDir, location), Title: track.Title, Duration: track.Duration(), Line: i + 1})
+		}
+	default:
+		playlist, err := m3u.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range playlist.Entries {
+			entries = append(entries, &Entry{Location: resolve(baseDir, e.Location), Title: e.Title, Duration: e.Duration, Line: e.Line})
+		}
+	}
+	return entries, nil
+}
+
+// resolve returns the absolute path of a location relative to `baseDir`, or the location itself for URLs.
+func resolve(baseDir string, location string) string {
+	if p, ok := (&m3u.Entry{Location: location}).Path(baseDir); ok {
+		if abs, err := filepath.Abs(p); err == nil {
+			return abs
+		}
+		return p
+	}
+	return location
+}
+
+// WriteOptions change how the entries are written.
+type WriteOptions struct {
+	// Relative writes the paths relative to the playlist's folder.
+	Relative bool
+}
+
+// Write writes `entries` to the playlist at `path`, in the format matching its extension.
+func Write(path string, entries []*Entry, options WriteOptions) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	baseDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	location := func(e *Entry) (string, bool) {
+		if e.IsURL() {
+			return e.Location, false
+		}
+		if options.Relative {
+			if rel, err := filepath.Rel(baseDir, e.Location); err == nil {
+				return rel, true
+			}
+		}
+		return e.Location, false
+	}
+
+	content := new(bytes.Buffer)
+	switch format {
+	case FormatPLS:
+		plsEntries := make([]*pls.Entry, len(entries))
+		for i, e := range entries {
+			loc, _ := location(e)
+			plsEntries[i] = &pls.Entry{Location: loc, Title: e.Title, Duration: e.Duration}
+		}
+		err = pls.Write(content, plsEntries)
+	case FormatXSPF:
+		playlist := &xspf.Playlist{Tracks: make([]*xspf.Track, len(entries))}
+		for i, e := range entries {
+			loc, relative := location(e)
+			switch {
+			case relative:
+				loc = (&url.URL{Path: filepath.ToSlash(loc)}).String()
+			case !e.IsURL():
+				loc = (&url.URL{Scheme: "file", Path: filepath.ToSlash(loc)}).String()
+			}
+			track := &xspf.Track{Location: loc, Title: e.Title}
+			track.SetDuration(e.Duration)
+			playlist.Tracks[i] = track
+		}
+		err = xspf.Write(content, playlist)
+	default:
+		playlist := &m3u.Playlist{Extended: true, Entries: make([]*m3u.Entry, len(entries))}
+		for i, e := range entries {
+			loc, _ := location(e)
+			playlist.Entries[i] = &m3u.Entry{Location: loc, Title: e.Title, Duration: e.Duration}
+		}
+		err = m3u.Write(content, playlist)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content.Bytes(), 0644)
+}
+
+// Rebase replaces the `from` prefix of the entries' paths with `to` (eg: to move a playlist from
+// one machine to another). URLs and paths not starting with `from` are left untouched.
+func Rebase(entries []*Entry, from string, to string) {
+	from = filepath.Clean(from)
+	for _, e := range entries {
+		if e.IsURL() {
+			continue
+		}
+		if e.Location == from {
+			e.Location = filepath.Clean(to)
+		} else if rest, ok := strings.CutPrefix(e.Location, strings.TrimSuffix(from, string(filepath.Separator))+string(filepath.Separator)); ok {
+			e.Location = filepath.Join(to, rest)
+		}
+	}
+}
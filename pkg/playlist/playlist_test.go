@@ -0,0 +1,83 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	original := []*Entry{
+		{Location: filepath.Join(dir, "music", "So What.mp3"), Title: "Miles Davis - So What", Duration: 562 * time.Second},
+		{Location: filepath.Join(dir, "music", "untitled.mp3"), Duration: -1},
+		{Location: "http://radio.example/live", Title: "Radio", Duration: -1},
+		{Location: filepath.Join(dir, "other", "ünïcödé & <xml>.flac"), Title: "Ünïcödé", Duration: 61 * time.Second},
+	}
+
+	for _, relative := range []bool{false, true} {
+		entries := original
+		for _, name := range []string{"a.m3u", "b.pls", "c.xspf", "d.m3u8", "e.xspf", "f.pls", "g.m3u"} {
+			path := filepath.Join(dir, "playlists", name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+			require.NoError(t, Write(path, entries, WriteOptions{Relative: relative}), name)
+			var err error
+			entries, err = Read(path)
+			require.NoError(t, err, name)
+			assert.Equal(t, original, withoutLines(entries), "after %s (relative: %t)", name, relative)
+		}
+	}
+}
+
+func Test_RoundTripDurations(t *testing.T) {
+	dir := t.TempDir()
+	original := []*Entry{
+		{Location: filepath.Join(dir, "zero.mp3"), Title: "Zero", Duration: 0},
+		{Location: filepath.Join(dir, "unknown.mp3"), Title: "Unknown", Duration: -1},
+		{Location: filepath.Join(dir, "known.mp3"), Title: "Known", Duration: 3 * time.Second},
+	}
+
+	entries := original
+	for _, name := range []string{"a.m3u", "b.xspf", "c.pls", "d.xspf", "e.m3u"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, Write(path, entries, WriteOptions{}), name)
+		var err error
+		entries, err = Read(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, original, withoutLines(entries), "after %s", name)
+	}
+}
+
+// withoutLines clears the line numbers set when reading, which are not written.
+func withoutLines(entries []*Entry) []*Entry {
+	cleared := make([]*Entry, len(entries))
+	for i, e := range entries {
+		copied := *e
+		copied.Line = 0
+		cleared[i] = &copied
+	}
+	return cleared
+}
+
+func Test_Rebase(t *testing.T) {
+	entries := []*Entry{
+		{Location: filepath.FromSlash("/home/me/Music/a.mp3")},
+		{Location: filepath.FromSlash("/home/me/Musical/b.mp3")},
+		{Location: "http://radio.example/home/me/Music"},
+	}
+	Rebase(entries, filepath.FromSlash("/home/me/Music/"), filepath.FromSlash("/mnt/music"))
+	assert.Equal(t, []*Entry{
+		{Location: filepath.FromSlash("/mnt/music/a.mp3")},
+		{Location: filepath.FromSlash("/home/me/Musical/b.mp3")},
+		{Location: "http://radio.example/home/me/Music"},
+	}, entries)
+}
+
+func Test_UnsupportedFormat(t *testing.T) {
+	_, err := Read("playlist.txt")
+	assert.EqualError(t, err, `unsupported playlist format: "playlist.txt"`)
+}
@@ -0,0 +1,81 @@
+package playlist
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Key returns the normalized location deciding if two entries are the same: cleaned paths
+// (case-insensitive on Windows and macOS) and URLs as they are.
+func Key(e *Entry) string {
+	if e.IsURL() {
+		return e.Location
+	}
+	key := filepath.Clean(e.Location)
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		key = strings.ToLower(key)
+	}
+	return key
+}
+
+// Union returns the entries present in any of the lists, in the order they first appear.
+func Union(lists ...[]*Entry) []*Entry {
+	seen := make(map[string]bool)
+	var result []*Entry
+	for _, list := range lists {
+		for _, e := range list {
+			if key := Key(e); !seen[key] {
+				seen[key] = true
+				result = append(result, e)
+			}
+		}
+	}
+	return result
+}
+
+// Intersect returns the entries of `first` also present in all the `others`, in the order of `first`.
+func Intersect(first []*Entry, others ...[]*Entry) []*Entry {
+	keySets := make([]map[string]bool, len(others))
+	for i, other := range others {
+		keySets[i] = keySet(other)
+	}
+	return filter(first, func(key string) bool {
+		for _, keys := range keySets {
+			if !keys[key] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Difference returns the entries of `first` present in none of the `others`, in the order of `first`.
+func Difference(first []*Entry, others ...[]*Entry) []*Entry {
+	excluded := keySet(Union(others...))
+	return filter(first, func(key string) bool {
+		return !excluded[key]
+	})
+}
+
+func keySet(entries []*Entry) map[string]bool {
+	keys := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		keys[Key(e)] = true
+	}
+	return keys
+}
+
+// filter returns the entries which key is kept, without duplicates.
+func filter(entries []*Entry, keep func(key string) bool) []*Entry {
+	seen := make(map[string]bool)
+	var result []*Entry
+	for _, e := range entries {
+		key := Key(e)
+		if !seen[key] && keep(key) {
+			seen[key] = true
+			result = append(result, e)
+		}
+	}
+	return result
+}
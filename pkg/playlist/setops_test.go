@@ -0,0 +1,30 @@
+package playlist
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func entries(locations ...string) []*Entry {
+	list := make([]*Entry, len(locations))
+	for i, location := range locations {
+		list[i] = &Entry{Location: filepath.FromSlash(location), Duration: -1}
+	}
+	return list
+}
+
+func Test_SetOperations(t *testing.T) {
+	favorites := entries("/music/c.mp3", "/music/a.mp3", "/music/./b.mp3", "http://radio.example/live", "/music/a.mp3")
+	played := entries("/music/b.mp3", "/music/d.mp3", "/other/../music/a.mp3")
+	skipped := entries("/music/a.mp3")
+
+	assert.Equal(t,
+		entries("/music/c.mp3", "/music/a.mp3", "/music/./b.mp3", "http://radio.example/live", "/music/d.mp3"),
+		Union(favorites, played))
+	assert.Equal(t, entries("/music/a.mp3", "/music/./b.mp3"), Intersect(favorites, played))
+	assert.Equal(t, entries("/music/a.mp3"), Intersect(favorites, played, skipped))
+	assert.Equal(t, entries("/music/c.mp3", "http://radio.example/live"), Difference(favorites, played))
+	assert.Equal(t, entries("/music/c.mp3", "/music/./b.mp3", "http://radio.example/live"), Difference(favorites, skipped))
+}
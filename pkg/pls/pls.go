@@ -0,0 +1,134 @@
+// Package pls reads and writes PLS playlists.
+package pls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	utf8BOM = "\uFEFF"
+)
+
+// Entry is an item of a PLS playlist.
+type Entry struct {
+	// Location of the media (`FileN`), as written in the playlist: a path (absolute or
+	// relative to the playlist) or a URL.
+	Location string
+	// Line is the number (starting at 1) of the line holding the location.
+	Line int
+	// Title of the media (`TitleN`).
+	Title string
+	// Duration of the media (`LengthN`). Negative when unknown (eg: live streams).
+	Duration time.Duration
+}
+
+// ParseFile reads the playlist at `path`.
+func ParseFile(path string) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Parse reads a PLS playlist, returning its entries ordered by their number.
+func Parse(r io.Reader) ([]*Entry, error) {
+	entriesByNumber := make(map[int]*Entry)
+	entry := func(number int) *Entry {
+		e, ok := entriesByNumber[number]
+		if !ok {
+			e = &Entry{Duration: -1}
+			entriesByNumber[number] = e
+		}
+		return e
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, utf8BOM)
+		}
+		line = strings.TrimSpace(line)
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue // section header (`[playlist]`), comment or blank line
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		for _, prefix := range []string{"file", "title", "length"} {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			number, err := strconv.Atoi(key[len(prefix):])
+			if err != nil {
+				break // eg: `NumberOfEntries`
+			}
+			switch prefix {
+			case "file":
+				entry(number).Location = value
+				entry(number).Line = lineNumber
+			case "title":
+				entry(number).Title = value
+			case "length":
+				seconds, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid length %q", lineNumber, value)
+				}
+				if seconds >= 0 {
+					entry(number).Duration = time.Duration(seconds) * time.Second
+				}
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0, len(entriesByNumber))
+	for number, e := range entriesByNumber {
+		if e.Location != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	entries := make([]*Entry, len(numbers))
+	for i, number := range numbers {
+		entries[i] = entriesByNumber[number]
+	}
+	return entries, nil
+}
+
+// Write writes a PLS (version 2) playlist.
+func Write(w io.Writer, entries []*Entry) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "[playlist]")
+	for i, entry := range entries {
+		number := i + 1
+		fmt.Fprintf(bw, "File%d=%s\n", number, entry.Location)
+		if entry.Title != "" {
+			fmt.Fprintf(bw, "Title%d=%s\n", number, entry.Title)
+		}
+		length := int64(-1)
+		if entry.Duration >= 0 {
+			length = int64(entry.Duration.Round(time.Second) / time.Second)
+		}
+		fmt.Fprintf(bw, "Length%d=%d\n", number, length)
+	}
+	fmt.Fprintf(bw, "NumberOfEntries=%d\n", len(entries))
+	fmt.Fprintln(bw, "Version=2")
+	return bw.Flush()
+}
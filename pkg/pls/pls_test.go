@@ -0,0 +1,33 @@
+package pls
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	content := "[playlist]\r\n" +
+		"File2=http://radio.example/stream\r\n" +
+		"Title2=Radio\r\n" +
+		"Length2=-1\r\n" +
+		"File1=music/a.mp3\r\n" +
+		"Title1=A\r\n" +
+		"Length1=215\r\n" +
+		"NumberOfEntries=2\r\n" +
+		"Version=2\r\n"
+	entries, err := Parse(strings.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, []*Entry{
+		{Location: "music/a.mp3", Line: 5, Title: "A", Duration: 215 * time.Second},
+		{Location: "http://radio.example/stream", Line: 2, Title: "Radio", Duration: -1},
+	}, entries)
+}
+
+func Test_ParseInvalidLength(t *testing.T) {
+	_, err := Parse(strings.NewReader("[playlist]\nFile1=a.mp3\nLength1=long\n"))
+	assert.EqualError(t, err, `line 3: invalid length "long"`)
+}
@@ -0,0 +1,345 @@
+// Package tags reads the metadata (artist, album, genre, ...) embedded in media files.
+//
+// Supported are ID3v2 (2.2, 2.3 and 2.4) and ID3v1 tags, as found in MP3 files, and the
+// Vorbis comments of FLAC files.
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrNoTags is returned when a file does not contain any supported tag.
+var ErrNoTags = errors.New("no supported tags found")
+
+// Tags is the metadata of a media file. Empty values are unknown.
+type Tags struct {
+	Title       string        `json:"title,omitempty"`
+	Artist      string        `json:"artist,omitempty"`
+	AlbumArtist string        `json:"album_artist,omitempty"`
+	Album       string        `json:"album,omitempty"`
+	Genre       string        `json:"genre,omitempty"`
+	Year        int           `json:"year,omitempty"`
+	Duration    time.Duration `json:"duration,omitempty"`
+}
+
+// ReadFile reads the tags of the file at `path`.
+func ReadFile(path string) (*Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads the tags of a media file.
+func Read(r io.ReadSeeker) (*Tags, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return nil, ErrNoTags
+		}
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch {
+	case string(magic) == "fLaC":
+		return readFLAC(r)
+	case string(magic[:3]) == "ID3":
+		return readID3v2(r)
+	default:
+		return readID3v1(r)
+	}
+}
+
+func readID3v2(r io.ReadSeeker) (*Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	version := header[3]
+	flags := header[5]
+	// The size comes from the file: check it against what the file contains before allocating.
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(int64(len(header)), io.SeekStart); err != nil {
+		return nil, err
+	}
+	size := syncSafe(header[6:10])
+	if int64(size) > end-int64(len(header)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if flags&0x80 != 0 { // unsynchronisation
+		body = bytes.ReplaceAll(body, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+	if flags&0x40 != 0 && version >= 3 && len(body) >= 4 { // extended header
+		size := int(binary.BigEndian.Uint32(body[:4]))
+		if version == 4 {
+			size = syncSafe(body[:4])
+		} else {
+			size += 4
+		}
+		if size > len(body) {
+			return nil, ErrNoTags
+		}
+		body = body[size:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	t := new(Tags)
+	for len(body) >= headerLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var size int
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		default:
+			size = syncSafe(body[4:8])
+		}
+		if size < 0 || headerLen+size > len(body) {
+			break
+		}
+		t.setID3Frame(id, decodeID3Text(body[headerLen:headerLen+size]))
+		body = body[headerLen+size:]
+	}
+	return t, nil
+}
+
+func (t *Tags) setID3Frame(id string, value string) {
+	switch id {
+	case "TIT2", "TT2":
+		t.Title = value
+	case "TPE1", "TP1":
+		t.Artist = value
+	case "TPE2", "TP2":
+		t.AlbumArtist = value
+	case "TALB", "TAL":
+		t.Album = value
+	case "TCON", "TCO":
+		t.Genre = id3Genre(value)
+	case "TYER", "TYE", "TDRC":
+		t.Year = parseYear(value)
+	case "TLEN", "TLE":
+		if ms, err := strconv.Atoi(value); err == nil {
+			t.Duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+}
+
+func decodeID3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+	encoding, data := frame[0], frame[1:]
+	var text string
+	switch encoding {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		bigEndian := encoding == 2
+		if len(data) >= 2 {
+			if data[0] == 0xFF && data[1] == 0xFE {
+				bigEndian, data = false, data[2:]
+			} else if data[0] == 0xFE && data[1] == 0xFF {
+				bigEndian, data = true, data[2:]
+			}
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if bigEndian {
+				units = append(units, binary.BigEndian.Uint16(data[i:]))
+			} else {
+				units = append(units, binary.LittleEndian.Uint16(data[i:]))
+			}
+		}
+		text = string(utf16.Decode(units))
+	case 3: // UTF-8
+		text = string(data)
+	default: // ISO-8859-1
+		text = latin1(data)
+	}
+	// Multiple values are separated by NUL characters: keep the first one.
+	if i := strings.IndexRune(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+func readID3v1(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return nil, ErrNoTags
+	}
+	block := make([]byte, 128)
+	if _, err := io.ReadFull(r, block); err != nil {
+		return nil, err
+	}
+	if string(block[:3]) != "TAG" {
+		return nil, ErrNoTags
+	}
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return strings.TrimSpace(latin1(b))
+	}
+	t := &Tags{
+		Title:  field(block[3:33]),
+		Artist: field(block[33:63]),
+		Album:  field(block[63:93]),
+		Year:   parseYear(field(block[93:97])),
+	}
+	if int(block[127]) < len(id3v1Genres) {
+		t.Genre = id3v1Genres[block[127]]
+	}
+	return t, nil
+}
+
+func readFLAC(r io.Reader) (*Tags, error) {
+	if _, err := io.ReadFull(r, make([]byte, 4)); err != nil {
+		return nil, err
+	}
+	t := new(Tags)
+	for last := false; !last; {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		last = header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		block := make([]byte, int(header[1])<<16|int(header[2])<<8|int(header[3]))
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		switch blockType {
+		case 0: // STREAMINFO
+			if len(block) >= 18 {
+				sampleRate := uint64(block[10])<<12 | uint64(block[11])<<4 | uint64(block[12])>>4
+				samples := uint64(block[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(block[14:18]))
+				if sampleRate > 0 {
+					t.Duration = time.Duration(samples * uint64(time.Second) / sampleRate)
+				}
+			}
+		case 4: // VORBIS_COMMENT
+			t.setVorbisComments(block)
+		}
+	}
+	return t, nil
+}
+
+func (t *Tags) setVorbisComments(block []byte) {
+	next := func() (string, bool) {
+		if len(block) < 4 {
+			return "", false
+		}
+		size := int(binary.LittleEndian.Uint32(block))
+		if size < 0 || 4+size > len(block) {
+			return "", false
+		}
+		value := string(block[4 : 4+size])
+		block = block[4+size:]
+		return value, true
+	}
+	if _, ok := next(); !ok { // vendor string
+		return
+	}
+	if len(block) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+	for i := 0; i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		key, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			t.Title = value
+		case "ARTIST":
+			t.Artist = value
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			t.AlbumArtist = value
+		case "ALBUM":
+			t.Album = value
+		case "GENRE":
+			t.Genre = value
+		case "DATE", "YEAR":
+			t.Year = parseYear(value)
+		}
+	}
+}
+
+func syncSafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// parseYear extracts the year of a date such as `1969`, `1969-09-26` or `1969-09-26T10:00`.
+func parseYear(value string) int {
+	if len(value) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(value[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// id3Genre resolves ID3v1 genre references, such as `(17)` or `17`, used in ID3v2 `TCON` frames.
+func id3Genre(value string) string {
+	ref := value
+	if strings.HasPrefix(ref, "(") {
+		if end := strings.IndexByte(ref, ')'); end > 0 {
+			if end < len(ref)-1 {
+				return ref[end+1:] // `(17)Rock` refines the reference
+			}
+			ref = ref[1:end]
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 0 && n < len(id3v1Genres) {
+		return id3v1Genres[n]
+	}
+	return value
+}
+
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop", "Jazz", "Metal",
+	"New Age", "Oldies", "Other", "Pop", "R&B", "Rap", "Reggae", "Rock", "Techno", "Industrial",
+	"Alternative", "Ska", "Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk",
+	"Fusion", "Trance", "Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic",
+	"Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream", "Southern Rock", "Comedy", "Cult", "Gangsta",
+	"Top 40", "Christian Rap", "Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes",
+	"Trailer", "Lo-Fi", "Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
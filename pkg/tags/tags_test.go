@@ -0,0 +1,110 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ID3v23(t *testing.T) {
+	frames := new(bytes.Buffer)
+	writeFrame := func(id string, encoding byte, text []byte) {
+		frames.WriteString(id)
+		binary.Write(frames, binary.BigEndian, uint32(len(text)+1))
+		frames.Write([]byte{0, 0, encoding})
+		frames.Write(text)
+	}
+	writeFrame("TIT2", 3, []byte("Something"))
+	writeFrame("TPE1", 0, []byte("The Beatles"))
+	writeFrame("TALB", 1, []byte{0xFF, 0xFE, 'A', 0, 'b', 0, 'b', 0, 'e', 0, 'y', 0})
+	writeFrame("TCON", 0, []byte("(17)"))
+	writeFrame("TYER", 0, []byte("1969"))
+	writeFrame("TLEN", 0, []byte("182000"))
+
+	tag := []byte("ID3\x03\x00\x00")
+	size := frames.Len() + 10 // with some padding
+	tag = append(tag, byte(size>>21&0x7F), byte(size>>14&0x7F), byte(size>>7&0x7F), byte(size&0x7F))
+	tag = append(tag, frames.Bytes()...)
+	tag = append(tag, make([]byte, 10)...)
+	tag = append(tag, []byte("audio data")...)
+
+	tags, err := Read(bytes.NewReader(tag))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &Tags{
+			Title:    "Something",
+			Artist:   "The Beatles",
+			Album:    "Abbey",
+			Genre:    "Rock",
+			Year:     1969,
+			Duration: 182 * time.Second,
+		}, tags)
+	}
+}
+
+func Test_ID3v1(t *testing.T) {
+	block := make([]byte, 128)
+	copy(block, "TAG")
+	copy(block[3:], "So What")
+	copy(block[33:], "Miles Davis")
+	copy(block[63:], "Kind of Blue")
+	copy(block[93:], "1959")
+	block[127] = 8
+	data := append([]byte("audio data"), block...)
+
+	tags, err := Read(bytes.NewReader(data))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &Tags{Title: "So What", Artist: "Miles Davis", Album: "Kind of Blue", Genre: "Jazz", Year: 1959}, tags)
+	}
+}
+
+func Test_FLAC(t *testing.T) {
+	data := new(bytes.Buffer)
+	data.WriteString("fLaC")
+
+	streamInfo := make([]byte, 34)
+	// 44100 Hz on 20 bits, then 36 bits of total samples (10 seconds).
+	sampleRate, samples := uint64(44100), uint64(441000)
+	streamInfo[10] = byte(sampleRate >> 12)
+	streamInfo[11] = byte(sampleRate >> 4)
+	streamInfo[12] = byte(sampleRate << 4)
+	binary.BigEndian.PutUint32(streamInfo[14:], uint32(samples))
+	data.Write([]byte{0, 0, 0, byte(len(streamInfo))})
+	data.Write(streamInfo)
+
+	comments := new(bytes.Buffer)
+	writeString := func(s string) {
+		binary.Write(comments, binary.LittleEndian, uint32(len(s)))
+		comments.WriteString(s)
+	}
+	writeString("vendor")
+	binary.Write(comments, binary.LittleEndian, uint32(3))
+	writeString("TITLE=Blue in Green")
+	writeString("albumartist=Miles Davis")
+	writeString("DATE=1959-08-17")
+	data.Write([]byte{0x84, 0, 0, byte(comments.Len())})
+	data.Write(comments.Bytes())
+
+	tags, err := Read(bytes.NewReader(data.Bytes()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &Tags{Title: "Blue in Green", AlbumArtist: "Miles Davis", Year: 1959, Duration: 10 * time.Second}, tags)
+	}
+}
+
+func Test_NoTags(t *testing.T) {
+	_, err := Read(bytes.NewReader([]byte("not a media file")))
+	assert.ErrorIs(t, err, ErrNoTags)
+
+	_, err = Read(bytes.NewReader(nil))
+	assert.ErrorIs(t, err, ErrNoTags)
+}
+
+func Test_ID3v2SizeLargerThanFile(t *testing.T) {
+	// The header declares a tag of 0x0FFFFFFF bytes (256 MiB), the file only contains 3 of them.
+	content := []byte{'I', 'D', '3', 3, 0, 0, 0x7F, 0x7F, 0x7F, 0x7F, 'T', 'I', 'T'}
+	_, err := Read(bytes.NewReader(content))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
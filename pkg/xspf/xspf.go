@@ -0,0 +1,97 @@
+// Package xspf reads and writes XSPF ("spiff") playlists.
+package xspf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	namespace = "http://xspf.org/ns/0/"
+)
+
+// Playlist is the content of an XSPF file.
+type Playlist struct {
+	XMLName xml.Name `xml:"http://xspf.org/ns/0/ playlist"`
+	Version string   `xml:"version,attr"`
+	Title   string   `xml:"title,omitempty"`
+	Tracks  []*Track `xml:"trackList>track"`
+}
+
+// Track is an entry of an XSPF playlist.
+type Track struct {
+	// Location is the URI of the media (eg: `file:///music/a.mp3`, `http://...` or a relative URI).
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Album    string `xml:"album,omitempty"`
+	// DurationMilliseconds is the duration of the media, nil when unknown (a known duration can be 0).
+	DurationMilliseconds *int64 `xml:"duration,omitempty"`
+}
+
+// Duration returns the duration of the track, negative when unknown.
+func (t *Track) Duration() time.Duration {
+	if t.DurationMilliseconds == nil || *t.DurationMilliseconds < 0 {
+		return -1
+	}
+	return time.Duration(*t.DurationMilliseconds) * time.Millisecond
+}
+
+// SetDuration sets the duration of the track, which is omitted when negative (unknown).
+func (t *Track) SetDuration(d time.Duration) {
+	if d < 0 {
+		t.DurationMilliseconds = nil
+		return
+	}
+	milliseconds := d.Milliseconds()
+	t.DurationMilliseconds = &milliseconds
+}
+
+// ParseFile reads the playlist at `path`.
+func ParseFile(path string) (*Playlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	playlist, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return playlist, nil
+}
+
+// Parse reads an XSPF playlist.
+func Parse(r io.Reader) (*Playlist, error) {
+	playlist := new(Playlist)
+	if err := xml.NewDecoder(r).Decode(playlist); err != nil {
+		return nil, err
+	}
+	for _, track := range playlist.Tracks {
+		track.Location = strings.TrimSpace(track.Location)
+	}
+	return playlist, nil
+}
+
+// Write writes an XSPF (version 1) playlist.
+func Write(w io.Writer, playlist *Playlist) error {
+	out := *playlist
+	out.XMLName = xml.Name{Space: namespace, Local: "playlist"}
+	if out.Version == "" {
+		out.Version = "1"
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,38 @@
+package xspf
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	playlist, err := Parse(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <title>Jazz</title>
+  <trackList>
+    <track>
+      <location>
+        file:///music/So%20What.mp3
+      </location>
+      <title>So What</title>
+      <creator>Miles Davis</creator>
+      <duration>562000</duration>
+    </track>
+    <track><location>http://radio.example/live</location></track>
+    <track><location>silence.mp3</location><duration>0</duration></track>
+  </trackList>
+</playlist>`))
+	require.NoError(t, err)
+	assert.Equal(t, "Jazz", playlist.Title)
+	if assert.Len(t, playlist.Tracks, 3) {
+		assert.Equal(t, "file:///music/So%20What.mp3", playlist.Tracks[0].Location)
+		assert.Equal(t, "Miles Davis", playlist.Tracks[0].Creator)
+		assert.Equal(t, 562*time.Second, playlist.Tracks[0].Duration())
+		assert.Equal(t, time.Duration(-1), playlist.Tracks[1].Duration())
+		assert.Equal(t, time.Duration(0), playlist.Tracks[2].Duration())
+	}
+}
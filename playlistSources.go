@@ -0,0 +1,96 @@
+package m3ugen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/adeynack/m3ugen/pkg/m3u"
+	"github.com/adeynack/m3ugen/pkg/playlist"
+	"github.com/adeynack/m3ugen/pkg/tags"
+)
+
+// playlistSourceExtensions are the extensions of the `scan` entries read as playlists instead of folders.
+var playlistSourceExtensions = []string{"m3u", "m3u8", "pls", "xspf"}
+
+// isPlaylistSource indicates if a `scan` entry is a playlist file (as opposed to a folder).
+func isPlaylistSource(p string) bool {
+	if !slices.Contains(playlistSourceExtensions, strings.ToLower(fileExtension(p))) {
+		return false
+	}
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// expandPlaylistSource sends the entries of a playlist used as a scan source to the same channels
+// `scanFolderWorker` uses: files to be filtered, and folders to be scanned. Nested playlists are
+// expanded as well. `parents` are the playlists being expanded, to detect loops.
+func (r *ScanRun) expandPlaylistSource(
+	playlistPath string,
+	parents []string,
+	folderToScanChanIn chan<- string,
+	filesToConsiderChan chan<- string,
+	errChan chan<- error,
+	foldersToScanWG *sync.WaitGroup,
+) {
+	absolutePath, err := filepath.Abs(playlistPath)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	if slices.Contains(parents, absolutePath) {
+		errChan <- fmt.Errorf("playlist %q includes itself (through %s), ignoring it", playlistPath, strings.Join(parents, " -> "))
+		return
+	}
+	parents = append(parents, absolutePath)
+
+	r.verbose("Reading playlist %q", playlistPath)
+	entries, err := playlist.Read(playlistPath)
+	if err != nil {
+		errChan <- err
+		return
+	}
+	for _, entry := range entries {
+		if isURL(entry.Location) {
+			r.addPlaylistSourceMetadata(entry)
+			filesToConsiderChan <- entry.Location
+			continue
+		}
+		info, err := os.Stat(entry.Location)
+		switch {
+		case err != nil:
+			errChan <- fmt.Errorf("entry of playlist %q: %w", playlistPath, err)
+		case info.IsDir():
+			foldersToScanWG.Add(1)
+			folderToScanChanIn <- entry.Location
+		case isPlaylistSource(entry.Location):
+			r.expandPlaylistSource(entry.Location, parents, folderToScanChanIn, filesToConsiderChan, errChan, foldersToScanWG)
+		default:
+			r.addPlaylistSourceMetadata(entry)
+			filesToConsiderChan <- entry.Location
+		}
+	}
+}
+
+// addPlaylistSourceMetadata keeps the title and duration a playlist gives to an entry.
+func (r *ScanRun) addPlaylistSourceMetadata(entry *playlist.Entry) {
+	if entry.Title == "" && entry.Duration <= 0 {
+		return
+	}
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]*tags.Tags)
+	}
+	t := &tags.Tags{Title: entry.Title}
+	if entry.Duration > 0 {
+		t.Duration = entry.Duration
+	}
+	r.Metadata[entry.Location] = t
+}
+
+// isURL indicates if a location is a URL (eg: `http://...`) rather than a path.
+func isURL(location string) bool {
+	return m3u.IsURL(location)
+}
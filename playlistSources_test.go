@@ -0,0 +1,68 @@
+package m3ugen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PlaylistsAsScanSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, folder := range []string{"music", "videos", "playlists"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, folder), os.ModePerm))
+	}
+	for _, file := range []string{"music/a.mp3", "music/b.mp3", "music/c.txt", "videos/d.mp4"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "playlists", "favorites.m3u"), []byte(
+		"#EXTM3U\n"+
+			"#EXTINF:61,Song A\n"+
+			"../music/a.mp3\n"+
+			"../music/c.txt\n"+
+			"../music/missing.mp3\n"+
+			"http://radio.example/live.mp3\n"+
+			"http://radio.example/jazz\n"+
+			"nested.pls\n",
+	), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "playlists", "nested.pls"), []byte(
+		"[playlist]\n"+
+			"File1="+filepath.Join(dir, "videos")+"\n"+
+			"File2=favorites.m3u\n"+
+			"File3=nested.xspf\n"+
+			"NumberOfEntries=3\n",
+	), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "playlists", "nested.xspf"), []byte(
+		`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+			`<playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList>`+
+			`<track><location>../music/b.mp3</location><title>Song B</title><duration>62000</duration></track>`+
+			`</trackList></playlist>`,
+	), 0644))
+
+	config := NewDefaultConfig()
+	config.ScanFolders = []string{filepath.Join(dir, "playlists", "favorites.m3u")}
+	config.Extensions = []string{"mp3", "mp4"}
+	config.OutputPath = filepath.Join(dir, "out.m3u")
+	config.OutputOptions = OutputOptions{Format: FormatExtendedM3U}
+	_, err := Start(config)
+	require.NoError(t, err)
+
+	entries, err := parseGeneratedPlaylist(config.OutputPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"#EXTM3U",
+			"#EXTINF:61,Song A",
+			filepath.Join(dir, "music", "a.mp3"),
+			"#EXTINF:62,Song B",
+			filepath.Join(dir, "music", "b.mp3"),
+			"#EXTINF:-1,d",
+			filepath.Join(dir, "videos", "d.mp4"),
+			"#EXTINF:-1,jazz",
+			"http://radio.example/jazz",
+			"#EXTINF:-1,live",
+			"http://radio.example/live.mp3",
+		}, entries)
+	}
+}
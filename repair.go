@@ -0,0 +1,241 @@
+package m3ugen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adeynack/m3ugen/pkg/playlist"
+)
+
+const (
+	// RepairedByName is the method of entries relocated by their file name.
+	RepairedByName = "name"
+	// RepairedBySizeAndHash is the method of entries relocated to one of several identical files (same size and hash).
+	RepairedBySizeAndHash = "size+hash"
+	// RepairedByTags is the method of entries relocated by matching their title with the tags of the files.
+	RepairedByTags = "tags"
+
+	utf8BOM = "\uFEFF"
+)
+
+// RepairReport is the outcome of the repair of a playlist.
+type RepairReport struct {
+	// Playlist is the path of the repaired playlist.
+	Playlist string `json:"playlist"`
+	// Repaired are the entries relocated to a new path.
+	Repaired []*RepairedEntry `json:"repaired"`
+	// Unresolved are the missing entries which could not be relocated.
+	Unresolved []*UnresolvedEntry `json:"unresolved"`
+}
+
+// RepairedEntry is a missing playlist entry which was relocated.
+type RepairedEntry struct {
+	Line int    `json:"line"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Method is how the new location was found: `name`, `size+hash` or `tags`.
+	Method string `json:"method"`
+}
+
+// UnresolvedEntry is a missing playlist entry which could not be relocated.
+type UnresolvedEntry struct {
+	Line     int    `json:"line"`
+	Location string `json:"location"`
+	Reason   string `json:"reason"`
+}
+
+// repairIndex is a lookup index of the files of a scan.
+type repairIndex struct {
+	run    *ScanRun
+	byName map[string][]string
+}
+
+// RepairPlaylists relocates the missing entries of M3U or PLS playlists (XSPF playlists are rejected) by
+// searching the configured `scan` folders for the moved files, and rewrites the playlists with their new
+// paths (unless `dryRun`).
+// Files are matched by name first. When several files have that name, they are all considered the
+// same if they have the same size and hash. Otherwise, or when no file has that name, the title of
+// the entry (eg: from `#EXTINF`) is matched against the tags of the files. As the missing file cannot
+// be read, size and hash only compare the files sharing its name: a renamed file without a title in
+// the playlist cannot be found.
+func RepairPlaylists(config *Config, playlists []string, dryRun bool) ([]*RepairReport, error) {
+	missing := make(map[string][]*playlist.Entry, len(playlists))
+	needsTags := false
+	for _, playlistPath := range playlists {
+		if strings.EqualFold(fileExtension(playlistPath), "xspf") {
+			// The repaired entries are rewritten line by line, which only works for M3U and PLS playlists.
+			return nil, fmt.Errorf("%s: repairing XSPF playlists is not supported", playlistPath)
+		}
+		entries, err := playlist.Read(playlistPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if isURL(entry.Location) {
+				continue
+			}
+			if problem, _ := checkFile(entry.Location); problem == ProblemMissing {
+				missing[playlistPath] = append(missing[playlistPath], entry)
+				needsTags = needsTags || entry.Title != ""
+			}
+		}
+	}
+
+	var index *repairIndex
+	reports := make([]*RepairReport, 0, len(playlists))
+	for _, playlistPath := range playlists {
+		report := &RepairReport{Playlist: playlistPath, Repaired: []*RepairedEntry{}, Unresolved: []*UnresolvedEntry{}}
+		reports = append(reports, report)
+		if len(missing[playlistPath]) == 0 {
+			continue
+		}
+		if index == nil {
+			scanConfig := *config
+			scanConfig.ReadTags = config.ReadTags || needsTags
+			run, err := Scan(&scanConfig)
+			if err != nil {
+				return nil, err
+			}
+			index = newRepairIndex(run)
+		}
+
+		for _, entry := range missing[playlistPath] {
+			newPath, method, reason := index.locate(entry)
+			if newPath == "" {
+				report.Unresolved = append(report.Unresolved, &UnresolvedEntry{Line: entry.Line, Location: entry.Location, Reason: reason})
+			} else {
+				report.Repaired = append(report.Repaired, &RepairedEntry{Line: entry.Line, From: entry.Location, To: newPath, Method: method})
+			}
+		}
+		if len(report.Repaired) > 0 && !dryRun {
+			if err := rewritePlaylistEntries(playlistPath, report.Repaired); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return reports, nil
+}
+
+func newRepairIndex(run *ScanRun) *repairIndex {
+	index := &repairIndex{run: run, byName: make(map[string][]string)}
+	for _, p := range run.FoundFilesPaths {
+		name := filepath.Base(p)
+		index.byName[name] = append(index.byName[name], p)
+	}
+	return index
+}
+
+// locate finds the new path of a missing entry. When it cannot, it returns the reason why.
+func (ix *repairIndex) locate(entry *playlist.Entry) (newPath string, method string, reason string) {
+	candidates := ix.byName[filepath.Base(entry.Location)]
+	if len(candidates) == 1 {
+		return candidates[0], RepairedByName, ""
+	}
+	if len(candidates) > 1 && allIdentical(candidates) {
+		return candidates[0], RepairedBySizeAndHash, ""
+	}
+
+	if entry.Title != "" {
+		searched := candidates
+		if len(searched) == 0 {
+			searched = ix.run.FoundFilesPaths
+		}
+		var matches []string
+		for _, p := range searched {
+			if t := ix.run.Metadata[p]; t != nil && t.Title != "" &&
+				(strings.EqualFold(entry.Title, t.Title) || strings.EqualFold(entry.Title, t.Artist+" - "+t.Title)) {
+				matches = append(matches, p)
+			}
+		}
+		if len(matches) == 1 {
+			return matches[0], RepairedByTags, ""
+		}
+		if len(matches) > 1 {
+			return "", "", fmt.Sprintf("%d files have tags matching %q", len(matches), entry.Title)
+		}
+	}
+
+	if len(candidates) > 1 {
+		return "", "", fmt.Sprintf("%d different files are named %q", len(candidates), filepath.Base(entry.Location))
+	}
+	if entry.Title == "" {
+		// The missing file cannot be read, so its size and hash are unknown: a renamed file can only be
+		// found by the tags matching the entry's title.
+		return "", "", fmt.Sprintf("no file named %q, and no title to match with the tags of the files", filepath.Base(entry.Location))
+	}
+	return "", "", "no matching file found"
+}
+
+// allIdentical indicates if all files have the same size and content hash.
+func allIdentical(paths []string) bool {
+	var size int64
+	var hash []byte
+	for i, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return false
+		}
+		fileHash, err := hashFile(p)
+		if err != nil {
+			return false
+		}
+		if i > 0 && (info.Size() != size || !bytes.Equal(fileHash, hash)) {
+			return false
+		}
+		size, hash = info.Size(), fileHash
+	}
+	return true
+}
+
+func hashFile(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// rewritePlaylistEntries replaces the locations of the repaired entries, keeping all other lines as
+// they are. New locations are written in the form of the old ones: `file://` URIs, relative to the
+// playlist or absolute. A UTF-8 BOM starting the playlist is kept.
+func rewritePlaylistEntries(playlist string, repaired []*RepairedEntry) error {
+	content, err := os.ReadFile(playlist)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	for _, entry := range repaired {
+		if entry.Line < 1 || entry.Line > len(lines) {
+			return fmt.Errorf("%s: line %d is out of range", playlist, entry.Line)
+		}
+		line := lines[entry.Line-1]
+		ending := line[len(strings.TrimRight(line, "\r\n")):]
+		prefix, value := "", strings.TrimRight(line, "\r\n")
+		if entry.Line == 1 && strings.HasPrefix(value, utf8BOM) {
+			prefix, value = utf8BOM, strings.TrimPrefix(value, utf8BOM)
+		}
+		if key, location, found := strings.Cut(value, "="); found && strings.EqualFold(fileExtension(playlist), "pls") {
+			prefix, value = prefix+key+"=", location
+		}
+		newLocation := entry.To
+		if value = strings.TrimSpace(value); isURL(value) {
+			newLocation = fileURI(entry.To) // only `file://` URIs are repaired
+		} else if !filepath.IsAbs(value) {
+			if rel, err := filepath.Rel(filepath.Dir(playlist), entry.To); err == nil {
+				newLocation = rel
+			}
+		}
+		lines[entry.Line-1] = prefix + newLocation + ending
+	}
+	return os.WriteFile(playlist, []byte(strings.Join(lines, "")), 0644)
+}
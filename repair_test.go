@@ -0,0 +1,101 @@
+package m3ugen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RepairPlaylists(t *testing.T) {
+	dir := t.TempDir()
+	library := filepath.Join(dir, "library")
+	for _, folder := range []string{"moved", "copy1", "copy2", "other1", "other2"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(library, folder), os.ModePerm))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(library, "moved", "a.mp3"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(library, "copy1", "b.mp3"), []byte("b"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(library, "copy2", "b.mp3"), []byte("b"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(library, "other1", "c.mp3"), []byte("c1"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(library, "other2", "c.mp3"), []byte("c2"), 0644))
+	writeID3v1File(t, filepath.Join(library, "renamed.mp3"), "So What", "Miles Davis", "1959")
+	require.NoError(t, os.WriteFile(filepath.Join(library, "kept.mp3"), nil, 0644))
+
+	playlist := filepath.Join(dir, "playlist.m3u")
+	require.NoError(t, os.WriteFile(playlist, []byte("#EXTM3U\r\n"+
+		"library/kept.mp3\r\n"+
+		"old/a.mp3\r\n"+
+		"/old/b.mp3\r\n"+
+		"old/c.mp3\r\n"+
+		"#EXTINF:-1,Miles Davis - So What\r\n"+
+		"old/so-what.mp3\r\n"+
+		"old/unknown.mp3\r\n"+
+		"#EXTINF:-1,Nobody - Nothing\r\n"+
+		"old/nothing.mp3\r\n"), 0644))
+
+	config := NewDefaultConfig()
+	config.ScanFolders = []string{library}
+	reports, err := RepairPlaylists(config, []string{playlist}, false)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+
+	assert.Equal(t, []*RepairedEntry{
+		{Line: 3, From: filepath.Join(dir, "old", "a.mp3"), To: filepath.Join(library, "moved", "a.mp3"), Method: RepairedByName},
+		{Line: 4, From: filepath.FromSlash("/old/b.mp3"), To: filepath.Join(library, "copy1", "b.mp3"), Method: RepairedBySizeAndHash},
+		{Line: 7, From: filepath.Join(dir, "old", "so-what.mp3"), To: filepath.Join(library, "renamed.mp3"), Method: RepairedByTags},
+	}, reports[0].Repaired)
+	assert.Equal(t, []*UnresolvedEntry{
+		{Line: 5, Location: filepath.Join(dir, "old", "c.mp3"), Reason: `2 different files are named "c.mp3"`},
+		{Line: 8, Location: filepath.Join(dir, "old", "unknown.mp3"), Reason: `no file named "unknown.mp3", and no title to match with the tags of the files`},
+		{Line: 10, Location: filepath.Join(dir, "old", "nothing.mp3"), Reason: "no matching file found"},
+	}, reports[0].Unresolved)
+
+	content, err := os.ReadFile(playlist)
+	require.NoError(t, err)
+	assert.Equal(t, "#EXTM3U\r\n"+
+		"library/kept.mp3\r\n"+
+		filepath.Join("library", "moved", "a.mp3")+"\r\n"+
+		filepath.Join(library, "copy1", "b.mp3")+"\r\n"+
+		"old/c.mp3\r\n"+
+		"#EXTINF:-1,Miles Davis - So What\r\n"+
+		filepath.Join("library", "renamed.mp3")+"\r\n"+
+		"old/unknown.mp3\r\n"+
+		"#EXTINF:-1,Nobody - Nothing\r\n"+
+		"old/nothing.mp3\r\n", string(content))
+}
+
+func Test_RepairPlaylistsKeepsEntryForms(t *testing.T) {
+	dir := t.TempDir()
+	library := filepath.Join(dir, "library")
+	require.NoError(t, os.Mkdir(library, os.ModePerm))
+	for _, name := range []string{"a.mp3", "b.mp3", "kept.mp3"} {
+		require.NoError(t, os.WriteFile(filepath.Join(library, name), []byte(name), 0644))
+	}
+	kept := fileURI(filepath.Join(library, "kept.mp3"))
+	original := utf8BOM + "library/kept.mp3\r\n" + kept + "\r\n" + "#EXTINF:-1,Kept\r\n" + kept + "\r\n"
+
+	playlist := filepath.Join(dir, "playlist.m3u")
+	require.NoError(t, os.WriteFile(playlist, []byte(original), 0644))
+	require.NoError(t, rewritePlaylistEntries(playlist, nil))
+	content, err := os.ReadFile(playlist)
+	require.NoError(t, err)
+	assert.Equal(t, original, string(content), "an unchanged playlist should be kept byte for byte")
+
+	require.NoError(t, os.WriteFile(playlist, []byte(utf8BOM+"old/a.mp3\r\n"+
+		fileURI(filepath.Join(dir, "old", "b.mp3"))+"\r\n"+
+		kept+"\r\n"), 0644))
+	config := NewDefaultConfig()
+	config.ScanFolders = []string{library}
+	reports, err := RepairPlaylists(config, []string{playlist}, false)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Len(t, reports[0].Repaired, 2)
+
+	content, err = os.ReadFile(playlist)
+	require.NoError(t, err)
+	assert.Equal(t, utf8BOM+filepath.Join("library", "a.mp3")+"\r\n"+
+		fileURI(filepath.Join(library, "b.mp3"))+"\r\n"+
+		kept+"\r\n", string(content))
+}
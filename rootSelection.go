@@ -0,0 +1,93 @@
+package m3ugen
+
+import "slices"
+
+// selectAcrossRoots keeps at most `root_quota` entries of each scan root and, for randomized
+// outputs with `root_weights`, interleaves the roots so that any leading part of the list (and
+// so the playlist after truncation to `maximum`) follows their weights as closely as possible.
+// The order of the entries of each root is kept. Roots without a weight only come after all
+// the weighted entries.
+func (r *ScanRun) selectAcrossRoots(output *OutputConfig, fileList []string) []string {
+	var roots []string
+	filesByRoot := make(map[string][]string)
+	selected := make([]string, 0, len(fileList))
+	for _, f := range fileList {
+		root := r.scanRootOf(f)
+		if _, ok := filesByRoot[root]; !ok {
+			roots = append(roots, root)
+			filesByRoot[root] = nil
+		}
+		if output.RootQuota > 0 && len(filesByRoot[root]) >= output.RootQuota {
+			continue
+		}
+		filesByRoot[root] = append(filesByRoot[root], f)
+		selected = append(selected, f)
+	}
+	if !output.RandomizeList || len(output.RootWeights) == 0 {
+		return selected
+	}
+
+	totalWeight := 0.0
+	for _, weight := range output.RootWeights {
+		totalWeight += weight
+	}
+	// Each position goes to the weighted root which is the most behind its share so far.
+	selected = selected[:0]
+	taken := make(map[string]int)
+	for {
+		next, nextDeficit := "", 0.0
+		for _, root := range roots {
+			weight := output.RootWeights[root]
+			if weight <= 0 || taken[root] >= len(filesByRoot[root]) {
+				continue
+			}
+			deficit := weight*float64(len(selected)+1)/totalWeight - float64(taken[root])
+			if next == "" || deficit > nextDeficit {
+				next, nextDeficit = root, deficit
+			}
+		}
+		if next == "" {
+			break
+		}
+		selected = append(selected, filesByRoot[next][taken[next]])
+		taken[next]++
+	}
+	for _, root := range roots {
+		selected = append(selected, filesByRoot[root][taken[root]:]...)
+	}
+	return selected
+}
+
+// interleaveRoots alternates the entries of the scan roots (A1, B1, C1, A2, B2, ...), in the
+// order of `scan`. Entries found through other sources (eg: playlists) come last in each round.
+func (r *ScanRun) interleaveRoots(output *OutputConfig, fileList []string) []string {
+	var roots []string
+	for _, folder := range r.Config.ScanFolders {
+		if !slices.Contains(roots, folder) {
+			roots = append(roots, folder)
+		}
+	}
+	filesByRoot := make(map[string][]string)
+	for _, f := range fileList {
+		root := r.scanRootOf(f)
+		if !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+		filesByRoot[root] = append(filesByRoot[root], f)
+	}
+	if output.InterleaveSorted {
+		for _, files := range filesByRoot {
+			sortEntries(files, output.Sort)
+		}
+	}
+
+	interleaved := make([]string, 0, len(fileList))
+	for round := 0; len(interleaved) < len(fileList); round++ {
+		for _, root := range roots {
+			if round < len(filesByRoot[root]) {
+				interleaved = append(interleaved, filesByRoot[root][round])
+			}
+		}
+	}
+	return interleaved
+}
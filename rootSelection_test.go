@@ -0,0 +1,63 @@
+package m3ugen
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SelectAcrossRootsWeights(t *testing.T) {
+	r := &ScanRun{Config: &Config{ScanFolders: []string{"/music", "/podcasts", "/other"}}}
+	var fileList []string
+	for i := 0; i < 20; i++ {
+		fileList = append(fileList, fmt.Sprintf("/music/%02d.mp3", i))
+	}
+	for i := 0; i < 5; i++ {
+		fileList = append(fileList, fmt.Sprintf("/podcasts/%02d.mp3", i), fmt.Sprintf("/other/%02d.mp3", i))
+	}
+	output := &OutputConfig{RandomizeList: true, OutputOptions: OutputOptions{
+		RootWeights: map[string]float64{"/music": 60, "/podcasts": 40},
+	}}
+
+	selected := r.selectAcrossRoots(output, fileList)
+	assert.Len(t, selected, len(fileList))
+	assert.Equal(t, map[string]int{"/music": 6, "/podcasts": 4}, countByRoot(r, selected[:10]))
+	assert.Equal(t, []string{"/music/00.mp3", "/podcasts/00.mp3", "/music/01.mp3"}, selected[:3])
+	// Once the podcasts are exhausted, the rest of the music, then the roots without weight.
+	assert.Equal(t, map[string]int{"/music": 20, "/podcasts": 5}, countByRoot(r, selected[:25]))
+	assert.Equal(t, "/other/00.mp3", selected[25])
+}
+
+func Test_SelectAcrossRootsQuota(t *testing.T) {
+	r := &ScanRun{Config: &Config{ScanFolders: []string{"/music", "/podcasts"}}}
+	fileList := []string{"/music/a.mp3", "/music/b.mp3", "/podcasts/a.mp3", "/music/c.mp3", "/podcasts/b.mp3", "/podcasts/c.mp3"}
+	output := &OutputConfig{OutputOptions: OutputOptions{RootQuota: 2}}
+
+	assert.Equal(t, []string{"/music/a.mp3", "/music/b.mp3", "/podcasts/a.mp3", "/podcasts/b.mp3"}, r.selectAcrossRoots(output, fileList))
+}
+
+func countByRoot(r *ScanRun, fileList []string) map[string]int {
+	counts := make(map[string]int)
+	for _, f := range fileList {
+		counts[r.scanRootOf(f)]++
+	}
+	return counts
+}
+
+func Test_InterleaveRoots(t *testing.T) {
+	r := &ScanRun{Config: &Config{ScanFolders: []string{"/music", "/jingles"}}}
+	fileList := []string{"/jingles/b.mp3", "/music/c.mp3", "/music/a.mp3", "/jingles/a.mp3", "/music/b.mp3", "/elsewhere/x.mp3"}
+
+	assert.Equal(t, []string{
+		"/music/c.mp3", "/jingles/b.mp3", "/elsewhere/x.mp3",
+		"/music/a.mp3", "/jingles/a.mp3",
+		"/music/b.mp3",
+	}, r.interleaveRoots(&OutputConfig{}, fileList))
+
+	assert.Equal(t, []string{
+		"/music/a.mp3", "/jingles/a.mp3", "/elsewhere/x.mp3",
+		"/music/b.mp3", "/jingles/b.mp3",
+		"/music/c.mp3",
+	}, r.interleaveRoots(&OutputConfig{OutputOptions: OutputOptions{InterleaveSorted: true}}, fileList))
+}
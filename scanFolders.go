@@ -3,7 +3,7 @@ package m3ugen
 import (
 	"os"
 	"path"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
@@ -36,6 +36,10 @@ func (r *ScanRun) scan() error {
 	close(excludedExtensionChan)
 	miscWorkersWG.Wait()
 
+	// Workers deliver files in a non-deterministic order. Sorting makes successive runs
+	// produce the same playlist, so an unchanged library leaves the output file untouched.
+	sort.Strings(r.FoundFilesPaths)
+
 	r.verbose("scan completed")
 	return nil
 }
@@ -65,16 +69,29 @@ func (r *ScanRun) scanAllFolders(
 	filesToConsiderChan chan<- string,
 	errChan chan<- error,
 ) {
+	var folders, playlists []string
+	for _, source := range r.Config.ScanFolders {
+		if isPlaylistSource(source) {
+			playlists = append(playlists, source)
+		} else {
+			folders = append(folders, source)
+		}
+	}
+
 	// Start scan workers
 	waitGroup := new(sync.WaitGroup)
-	waitGroup.Add(len(r.Config.ScanFolders))
+	waitGroup.Add(len(folders))
 	for i := 0; i < r.Config.ScanFolderWorkers; i++ {
 		go r.scanFolderWorker(i, folderToScanChanIn, folderToScanChanOut, filesToConsiderChan, errChan, waitGroup)
 	}
 	// Feed with folders to scan
-	for _, folder := range r.Config.ScanFolders {
+	for _, folder := range folders {
 		folderToScanChanIn <- folder
 	}
+	// Expand the playlists to scan
+	for _, playlist := range playlists {
+		r.expandPlaylistSource(playlist, nil, folderToScanChanIn, filesToConsiderChan, errChan, waitGroup)
+	}
 	// Wait for recursive completion
 	waitGroup.Wait()
 }
@@ -130,7 +147,7 @@ func (r *ScanRun) receiveFilesWorker(
 	excludedExtensionChan chan<- string,
 ) {
 	defer receiveFilesWorkersWG.Done()
-	if len(r.Config.Extensions) == 0 {
+	if len(r.extensions) == 0 {
 		r.receiveFilesWorkerPlain(workerNumber, filesToConsiderChan, foundFileChan)
 	} else {
 		r.receiveFilesWorkerWithExtensionFilter(workerNumber, filesToConsiderChan, foundFileChan, excludedExtensionChan)
@@ -161,22 +178,12 @@ func (r *ScanRun) receiveFilesWorkerWithExtensionFilter(
 	r.FoundExtensions = make(map[string]bool)
 	for fullPath := range filesToConsiderChan {
 		r.debug("[receiveFilesWorkerWithExtensionFilter %d] Considering file: %s", workerNumber, fullPath)
-		matches := regexGetFileExtension.FindStringSubmatch(fullPath)
-		var currentFileExtension string
-		if len(matches) > 1 {
-			currentFileExtension = matches[len(matches)-1]
-		}
-		extensionExcluded := true
-		for _, configuredExtension := range r.Config.Extensions {
-			if strings.EqualFold(configuredExtension, currentFileExtension) {
-				r.debug("[receiveFilesWorkerWithExtensionFilter %d] File matches configured extension %q and is being considered: %s",
-					workerNumber, configuredExtension, fullPath)
-				foundFileChan <- fullPath
-				extensionExcluded = false // TODO: Inline `if` and get rid of this variable ==> lower-case the configured extensions (once at init) and use `slices.Contains` instead of this range/if trick.
-				break
-			}
-		}
-		if extensionExcluded {
+		currentFileExtension := fileExtension(fullPath)
+		if matchesExtensions(fullPath, r.extensions) {
+			r.debug("[receiveFilesWorkerWithExtensionFilter %d] File matches configured extension %q and is being considered: %s",
+				workerNumber, currentFileExtension, fullPath)
+			foundFileChan <- fullPath
+		} else {
 			r.debug("[receiveFilesWorkerWithExtensionFilter %d] File does not match any configured extension and is being ignored: %s",
 				workerNumber, fullPath)
 			excludedExtensionChan <- currentFileExtension
@@ -1,12 +1,15 @@
 package m3ugen
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"regexp"
 	"strings"
+
+	"github.com/adeynack/m3ugen/pkg/playlist"
+	"github.com/adeynack/m3ugen/pkg/tags"
 )
 
 const (
@@ -19,6 +22,22 @@ type ScanRun struct {
 
 	FoundFilesPaths []string
 
+	// extensions the scan filters files on (lower-cased). Empty means no filtering.
+	extensions []string
+
+	// Metadata holds the tags of the found files, by path, when they were read.
+	Metadata map[string]*tags.Tags
+
+	// Changes are the differences with the previous versions of the playlists, by path, for
+	// the outputs with `report_changes`.
+	Changes map[string]*playlist.Diff
+	// changesOutput is where the changes are reported. Default: the standard error.
+	changesOutput io.Writer
+
+	// inserts are the entries of the inserts' sources of the outputs, read once even when an
+	// output writes several playlists.
+	inserts map[*OutputConfig][]string
+
 	// FoundExtensions is a list of observed extensions. Value is true when
 	// the extension was considered and false when excluded.
 	FoundExtensions map[string]bool
@@ -31,15 +50,51 @@ var (
 	regexGetFileExtension = regexp.MustCompile(`^.*\.(.*)$`)
 )
 
+// fileExtension returns the extension of a path, without its leading dot.
+func fileExtension(path string) string {
+	matches := regexGetFileExtension.FindStringSubmatch(path)
+	if len(matches) > 1 {
+		return matches[len(matches)-1]
+	}
+	return ""
+}
+
 // Start begins the process of scanning and generating the playlist.
 func Start(config *Config) (*ScanRun, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
+	r, err := startScan(config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, output := range config.EffectiveOutputs() {
+		if err := r.writePlaylist(output); err != nil {
+			return nil, err
+		}
+	}
+
+	r.logExcludedExtensions()
+
+	return r, nil
+}
+
+// Scan scans the configured folders, without generating any playlist. Only the
+// scan related part of the configuration is used.
+func Scan(config *Config) (*ScanRun, error) {
+	if err := config.ValidateScan(); err != nil {
+		return nil, err
+	}
+	return startScan(config)
+}
+
+func startScan(config *Config) (*ScanRun, error) {
 	r := &ScanRun{
 		Config:          config,
 		FoundFilesPaths: make([]string, 0, initialFoundFilesPathCapacity),
+		extensions:      config.scanExtensions(),
 	}
 	r.initializeVerboseAndDebugOutputs()
 	r.debug("Starting scan & generate process using config %+v", config)
@@ -47,17 +102,16 @@ func Start(config *Config) (*ScanRun, error) {
 	if err := r.scan(); err != nil {
 		return nil, err
 	}
+	r.addStreams()
 
-	if config.DetectDuplicates {
-		r.detectDuplicates()
+	if config.needsTags() {
+		r.readTags()
 	}
 
-	if err := r.writePlaylist(); err != nil {
-		return nil, err
+	if config.DetectDuplicates {
+		r.detectDuplicates()
 	}
 
-	r.logExcludedExtensions()
-
 	return r, nil
 }
 
@@ -82,48 +136,6 @@ func (r *ScanRun) initializeVerboseAndDebugOutputs() {
 	}
 }
 
-func (r *ScanRun) writePlaylist() (err error) {
-	fileList := make([]string, len(r.FoundFilesPaths))
-	copy(fileList, r.FoundFilesPaths)
-	if r.Config.RandomizeList {
-		r.verbose("Shuffling the found files")
-		ShuffleSlice(fileList)
-	}
-
-	foundFilesPathsCount := len(r.FoundFilesPaths)
-	max := r.Config.MaximumEntries
-	if max < 1 {
-		r.verbose("No maximum entries. Writing all %d files to output.", foundFilesPathsCount)
-		max = foundFilesPathsCount
-	} else if max > foundFilesPathsCount {
-		r.verbose("Limited to %d. Writing all %d found files to output.", max, foundFilesPathsCount)
-		max = foundFilesPathsCount
-	} else {
-		r.verbose("Limited to %d. Writing the first %d found files to output.", max, max)
-	}
-
-	r.verbose("Writing playlist to %s", r.Config.OutputPath)
-	f, err := os.OpenFile(r.Config.OutputPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
-	if err != nil {
-		return
-	}
-	defer func() {
-		err = FirstErr(err, f.Close())
-	}()
-
-	w := bufio.NewWriter(f)
-	defer func() {
-		err = FirstErr(err, w.Flush())
-	}()
-	for _, entry := range fileList[:max] {
-		_, err = fmt.Fprintln(w, entry)
-		if err != nil {
-			return
-		}
-	}
-	return
-}
-
 func (r *ScanRun) logExcludedExtensions() {
 	if !r.Config.Verbose {
 		return
@@ -158,3 +170,19 @@ func (r *ScanRun) detectDuplicates() {
 	}
 	r.verbose("%d files were detected as duplicates", duplicatesCount)
 }
+
+// PlaylistEntries returns the found files as playlist entries, with their title and duration when known.
+func (r *ScanRun) PlaylistEntries() []*playlist.Entry {
+	entries := make([]*playlist.Entry, len(r.FoundFilesPaths))
+	for i, p := range r.FoundFilesPaths {
+		entry := &playlist.Entry{Location: p, Duration: -1}
+		if t := r.Metadata[p]; t != nil {
+			entry.Title = t.Title
+			if t.Duration > 0 {
+				entry.Duration = t.Duration
+			}
+		}
+		entries[i] = entry
+	}
+	return entries
+}
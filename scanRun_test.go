@@ -2,14 +2,19 @@ package m3ugen
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/adeynack/m3ugen/pkg/playlist"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -64,8 +69,12 @@ func Test_FullConfigAndScan_Maximum3(t *testing.T) {
 	config.RandomizeList = false
 	config.MaximumEntries = 3
 	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
-		assert.Len(t, entries, 3)
-		// which one of the 3 entries got chosen is non-deterministic and cannot be asserted.
+		// the found files are sorted by path, so the first 3 of them are kept.
+		assert.Equal(t, []string{
+			filepath.Join(basePath, "folder1", "file2.mp4"),
+			filepath.Join(basePath, "folder2", "file1.mpg"),
+			filepath.Join(basePath, "folder2", "file2.mpg"),
+		}, entries)
 	})
 }
 
@@ -96,6 +105,377 @@ func Test_DeepFolderStructure(t *testing.T) {
 	})
 }
 
+func Test_UnchangedPlaylistIsNotRewritten(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mpg", "mp4"}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		past := time.Now().Add(-time.Hour).Truncate(time.Second)
+		if !assert.NoError(t, os.Chtimes(config.OutputPath, past, past)) {
+			return
+		}
+		_, err := Start(config)
+		if !assert.NoError(t, err) {
+			return
+		}
+		info, err := os.Stat(config.OutputPath)
+		if assert.NoError(t, err) {
+			assert.True(t, info.ModTime().Equal(past), "playlist should not have been rewritten")
+		}
+	})
+}
+
+func Test_MultipleOutputs(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Outputs = []*OutputConfig{
+		{Path: "playlist.m3u", Extensions: []string{"mpg", "mp4"}},
+		{Path: "mp4.m3u", Extensions: []string{"mp4"}},
+		{Path: "random.m3u", Extensions: []string{"mpg"}, RandomizeList: true, MaximumEntries: 2},
+		{Path: "extended.m3u", Extensions: []string{"mp4"}, OutputOptions: OutputOptions{Format: FormatExtendedM3U}},
+	}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		assert.Len(t, entries, 8)
+
+		mp4Entries, err := parseGeneratedPlaylist(filepath.Join(basePath, "mp4.m3u"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Join(basePath, "folder1", "file2.mp4")}, mp4Entries)
+		}
+
+		randomEntries, err := parseGeneratedPlaylist(filepath.Join(basePath, "random.m3u"))
+		if assert.NoError(t, err) {
+			assert.Len(t, randomEntries, 2)
+		}
+
+		extendedEntries, err := parseGeneratedPlaylist(filepath.Join(basePath, "extended.m3u"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{
+				"#EXTM3U",
+				"#EXTINF:-1,file2",
+				filepath.Join(basePath, "folder1", "file2.mp4"),
+			}, extendedEntries)
+		}
+	})
+}
+
+func Test_PerFolderPlaylists(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Outputs = []*OutputConfig{
+		{Path: "playlist.m3u", Extensions: []string{"mpg", "mp4"}, OutputOptions: OutputOptions{Mode: ModePerFolder}},
+		{Extensions: []string{"mpg"}, OutputOptions: OutputOptions{
+			Mode:         ModePerFolder,
+			NameTemplate: "{{.ParentName}} - {{.FolderName}}.m3u",
+			Placement:    PlacementBeside,
+		}},
+		{Extensions: []string{"mp4"}, OutputOptions: OutputOptions{
+			Mode:         ModePerFolder,
+			NameTemplate: "{{.FolderPath}}/../{{.FolderName}}.m3u",
+		}},
+	}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		assert.Len(t, entries, 8)
+		assert.Contains(t, entries, filepath.Join("folder2", "subfolder1", "file3.mpg"))
+
+		folderEntries, err := parseGeneratedPlaylist(filepath.Join(basePath, "folder2", "subfolder1", "subfolder1.m3u"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"file3.mpg", "file4.mpg"}, folderEntries)
+		}
+
+		besideEntries, err := parseGeneratedPlaylist(filepath.Join(basePath, "folder2", "folder2 - subfolder2.m3u"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Join("subfolder2", "file3.mpg"), filepath.Join("subfolder2", "file4.mpg")}, besideEntries)
+		}
+
+		pathName := SanitizeFileName(filepath.Join(basePath, "folder1") + "/../folder1.m3u")
+		pathEntries, err := parseGeneratedPlaylist(filepath.Join(basePath, "folder1", pathName))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"file2.mp4"}, pathEntries)
+		}
+	})
+}
+
+func Test_PerFolderPlaylistsWithStreams(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mp4"}
+	config.Streams = []*StreamConfig{{URL: "http://radio.example/jazz", Title: "Jazz Radio", Duration: -1}}
+	config.OutputOptions = OutputOptions{Mode: ModePerFolder}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		assert.Equal(t, []string{filepath.Join("folder1", "file2.mp4"), "http://radio.example/jazz"}, entries,
+			"the aggregating playlist has the stream")
+
+		folderEntries, err := parseGeneratedPlaylist(filepath.Join(basePath, "folder1", "folder1.m3u"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"file2.mp4"}, folderEntries)
+		}
+	})
+}
+
+func Test_TemplateFormat(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "playlist.tmpl")
+	err := os.WriteFile(templatePath, []byte(
+		`{{define "header"}}<playlist count="{{.Count}}">{{"\n"}}{{end}}`+
+			`{{define "entry"}}<track index="{{.Index}}" size="{{.Size}}">{{.RelativePath}}</track>{{"\n"}}{{end}}`+
+			`{{define "footer"}}</playlist>{{"\n"}}{{end}}`,
+	), 0644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mp4"}
+	config.OutputOptions = OutputOptions{Format: FormatTemplate, Template: templatePath}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		assert.Equal(t, []string{
+			`<playlist count="1">`,
+			`<track index="1" size="0">` + filepath.Join("folder1", "file2.mp4") + `</track>`,
+			`</playlist>`,
+		}, entries)
+	})
+}
+
+func Test_TemplateParsedOnce(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "playlist.tmpl")
+	if !assert.NoError(t, os.WriteFile(templatePath, []byte(`{{define "entry"}}{{.Path}}{{end}}`), 0644)) {
+		return
+	}
+	config := NewDefaultConfig()
+	config.OutputPath = "playlist.txt"
+	config.ScanFolders = []string{"."}
+	config.Format = FormatTemplate
+	config.Template = templatePath
+	if !assert.NoError(t, config.Validate()) {
+		return
+	}
+	assert.NoError(t, os.Remove(templatePath))
+	output := config.EffectiveOutputs()[0]
+	first, err := output.outputTemplate()
+	if !assert.NoError(t, err, "the template parsed by Validate should be reused") {
+		return
+	}
+	second, err := output.outputTemplate()
+	if assert.NoError(t, err) {
+		assert.Same(t, first, second)
+	}
+}
+
+func Test_ExportFormats(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Outputs = []*OutputConfig{
+		{Path: "playlist.m3u", Extensions: []string{"mp4"}, OutputOptions: OutputOptions{Format: FormatJSONLines}},
+		{Path: "library.csv", Extensions: []string{"mp4"}, OutputOptions: OutputOptions{Format: FormatCSV}},
+	}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		if assert.Len(t, entries, 1) {
+			var record ExportRecord
+			if assert.NoError(t, json.Unmarshal([]byte(entries[0]), &record)) {
+				assert.Equal(t, ExportSchemaVersion, record.SchemaVersion)
+				assert.Equal(t, filepath.Join(basePath, "folder1", "file2.mp4"), record.Path)
+				assert.Equal(t, basePath, record.Root)
+				assert.Equal(t, "mp4", record.Extension)
+				assert.Equal(t, FileTypeVideo, record.Type)
+				assert.Nil(t, record.Metadata)
+			}
+		}
+
+		csvLines, err := parseGeneratedPlaylist(filepath.Join(basePath, "library.csv"))
+		if assert.NoError(t, err) && assert.Len(t, csvLines, 2) {
+			assert.Equal(t, "schema_version,path,root,size,mtime,extension,type,duplicate_group,"+
+				"title,artist,album_artist,album,genre,year,duration_seconds", csvLines[0])
+			assert.Contains(t, csvLines[1], ",mp4,video,0,")
+		}
+	})
+}
+
+func Test_ReportChanges(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mp4"}
+	config.OutputOptions = OutputOptions{ReportChanges: true}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		config.Extensions = []string{"mpg"}
+		config.MaximumEntries = 1
+		r, err := Start(config)
+		if !assert.NoError(t, err) {
+			return
+		}
+		diff := r.Changes[config.OutputPath]
+		if assert.NotNil(t, diff) {
+			assert.Len(t, diff.Added, 1)
+			if assert.Len(t, diff.Removed, 1) {
+				assert.Equal(t, filepath.Join(basePath, "folder1", "file2.mp4"), diff.Removed[0].Location)
+			}
+			assert.Empty(t, diff.Moved)
+		}
+	})
+}
+
+func Test_ReportChangesJSON(t *testing.T) {
+	dir := t.TempDir()
+	playlistPath := filepath.Join(dir, "playlist.m3u")
+	if !assert.NoError(t, os.WriteFile(playlistPath, []byte("/music/a.mp3\n/music/b.mp3\n"), 0644)) {
+		return
+	}
+	changes := new(bytes.Buffer)
+	r := &ScanRun{Config: NewDefaultConfig(), changesOutput: changes, verbose: func(string, ...any) {}}
+	output := &OutputConfig{OutputOptions: OutputOptions{ReportChanges: true, ReportChangesFormat: ReportChangesJSON}}
+	r.reportChanges(output, playlistPath, []string{"/music/b.mp3", "/music/c.mp3"})
+
+	report := new(ChangesReport)
+	if assert.NoError(t, json.Unmarshal(changes.Bytes(), report)) {
+		assert.Equal(t, playlistPath, report.Playlist)
+		assert.Equal(t, []*playlist.DiffEntry{{Location: "/music/c.mp3", NewPosition: 2}}, report.Added)
+		assert.Equal(t, []*playlist.DiffEntry{{Location: "/music/a.mp3", OldPosition: 1}}, report.Removed)
+	}
+	assert.Equal(t, 1, strings.Count(changes.String(), "\n"), "one line per playlist")
+}
+
+func Test_PinnedEntries(t *testing.T) {
+	intro := filepath.Join(t.TempDir(), "intro.mp3")
+	if !assert.NoError(t, os.WriteFile(intro, nil, 0644)) {
+		return
+	}
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mpg", "mp4"}
+	config.RandomizeList = true
+	config.MaximumEntries = 4
+	config.OutputOptions = OutputOptions{
+		Prepend:         []string{intro},
+		Append:          []string{"http://radio.example/sign-off.mp3"},
+		PinnedInMaximum: true,
+	}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		if assert.Len(t, entries, 4) {
+			assert.Equal(t, intro, entries[0])
+			assert.Equal(t, "http://radio.example/sign-off.mp3", entries[3])
+		}
+	})
+}
+
+func Test_InvalidConfig_MissingPinnedEntry(t *testing.T) {
+	config := NewDefaultConfig()
+	config.OutputPath = "foo.m3u"
+	config.ScanFolders = []string{"."}
+	config.Append = []string{"missing-sign-off.mp3"}
+	_, err := Start(config)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "invalid output #1: invalid pinned entry: "), err.Error())
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	}
+}
+
+func Test_Streams(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mp4"}
+	config.Streams = []*StreamConfig{
+		{URL: "http://radio.example/jazz", Title: "Jazz Radio", Duration: -1},
+		{URL: "https://podcast.example/episode-1.mp3", Title: "Episode 1", Duration: 1800},
+	}
+	config.OutputOptions = OutputOptions{Format: FormatExtendedM3U, RelativePaths: true}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		assert.Equal(t, []string{
+			"#EXTM3U",
+			"#EXTINF:-1,file2",
+			filepath.Join("folder1", "file2.mp4"),
+			"#EXTINF:-1,Jazz Radio",
+			"http://radio.example/jazz",
+			"#EXTINF:1800,Episode 1",
+			"https://podcast.example/episode-1.mp3",
+		}, entries)
+	})
+}
+
+func Test_InvalidConfig_StreamWithoutURL(t *testing.T) {
+	config := NewDefaultConfig()
+	config.OutputPath = "foo.m3u"
+	config.Streams = []*StreamConfig{{URL: "radio", Title: "Radio"}}
+	_, err := Start(config)
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid stream #1: stream "Radio" requires a URL`, err.Error())
+	}
+}
+
+func Test_WhereExpression(t *testing.T) {
+	dir := t.TempDir()
+	writeID3v1File(t, filepath.Join(dir, "a.mp3"), "So What", "Miles Davis", "1959")
+	writeID3v1File(t, filepath.Join(dir, "b.mp3"), "Highway to Hell", "AC/DC", "1979")
+	writeID3v1File(t, filepath.Join(dir, "c live.mp3"), "Bad Boy Boogie", "AC/DC", "1978")
+
+	config := NewDefaultConfig()
+	config.ScanFolders = []string{dir}
+	config.OutputPath = filepath.Join(dir, "playlist.m3u")
+	config.Where = `artist == "ac/dc" and year >= 1970 and not name ~ "live"`
+	_, err := Start(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	entries, err := parseGeneratedPlaylist(config.OutputPath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{filepath.Join(dir, "b.mp3")}, entries)
+	}
+}
+
+func Test_InvalidConfig_WhereExpression(t *testing.T) {
+	config := NewDefaultConfig()
+	config.OutputPath = "foo.m3u"
+	config.ScanFolders = []string{"."}
+	config.Where = `genre == "Jazz" and yaer >= 1960`
+	_, err := Start(config)
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid where expression: column 21: unknown field "yaer"`, err.Error())
+	}
+
+	config.Where = ""
+	config.Outputs = []*OutputConfig{{Path: "a.m3u"}, {Path: "b.m3u", OutputOptions: OutputOptions{Where: `year ~ "19"`}}}
+	assert.EqualError(t, config.ValidateValues(), `invalid output #2: invalid where expression: column 6: operator "~" cannot be used on number field "year"`)
+}
+
+func Test_Stats(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Extensions = []string{"mpg", "mp4"}
+	config.Streams = []*StreamConfig{{URL: "http://radio.example/jazz"}}
+	withTestFolder(t, testStructure01, config, func(t *testing.T, basePath string, entries []string) {
+		r, err := Scan(config)
+		if assert.NoError(t, err) {
+			assert.Equal(t, &Stats{
+				Files:       9,
+				ByRoot:      map[string]int{basePath: 8, "": 1},
+				ByExtension: map[string]int{"mpg": 7, "mp4": 1},
+			}, r.Stats())
+		}
+	})
+}
+
+func Test_InvalidConfig_Ranges(t *testing.T) {
+	for expected, change := range map[string]func(config *Config){
+		`"scanRun_test.go" is neither a folder nor a playlist to scan (ScanFolders)`:     func(config *Config) { config.ScanFolders = []string{"scanRun_test.go"} },
+		`configuration requires at least one scan folder worker (ScanFolderWorkers)`:     func(config *Config) { config.ScanFolderWorkers = 0 },
+		`configuration requires at least one receive files worker (ReceiveFilesWorkers)`: func(config *Config) { config.ReceiveFilesWorkers = -1 },
+		`channels buffer size cannot be negative (ChannelsBufferSize)`:                   func(config *Config) { config.ChannelsBufferSize = -1 },
+	} {
+		config := NewDefaultConfig()
+		config.OutputPath = "foo.m3u"
+		config.ScanFolders = []string{"."}
+		change(config)
+		assert.EqualError(t, config.Validate(), expected)
+		assert.EqualError(t, config.ValidateValues(), expected)
+	}
+
+	config := NewDefaultConfig()
+	config.OutputPath = "foo.m3u"
+	config.ScanFolders = []string{"missing"}
+	err := config.Validate()
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "invalid folder to scan: "), err.Error())
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	}
+	assert.ErrorIs(t, config.ValidateValues(), fs.ErrNotExist)
+}
+
+func Test_InvalidConfig_RootWeights(t *testing.T) {
+	config := NewDefaultConfig()
+	config.OutputPath = "foo.m3u"
+	config.ScanFolders = []string{"."}
+	config.RootWeights = map[string]float64{".": 1, "/elsewhere": 1}
+	assert.EqualError(t, config.Validate(), `invalid output #1: root weight "/elsewhere" does not match any folder to scan (ScanFolders)`)
+}
+
 type entriesTest struct {
 	t        *testing.T
 	basePath string
@@ -144,6 +524,11 @@ func withTestFolder(
 	// SCAN AND GENERATE M3U
 	testConfiguration.ScanFolders = []string{testFolderName}
 	testConfiguration.OutputPath = filepath.Join(testFolderName, "playlist.m3u")
+	for _, output := range testConfiguration.Outputs {
+		if output.Path != "" {
+			output.Path = filepath.Join(testFolderName, output.Path)
+		}
+	}
 	Start(testConfiguration)
 
 	// PARSE THE GENERATED M3U
@@ -0,0 +1,38 @@
+package m3ugen
+
+import (
+	"strings"
+	"time"
+)
+
+// Stats summarizes the result of a scan.
+type Stats struct {
+	// Files is the number of entries found.
+	Files int `json:"files"`
+	// ByRoot is the number of entries found in each scan root. Entries found through
+	// playlists used as sources, and streams, are counted under "".
+	ByRoot map[string]int `json:"by_root"`
+	// ByExtension is the number of entries for each (lower-cased) extension.
+	ByExtension map[string]int `json:"by_extension"`
+	// Duration is the total duration of the entries which duration is known (from their tags).
+	Duration time.Duration `json:"duration"`
+}
+
+// Stats returns the statistics of the scan result.
+func (r *ScanRun) Stats() *Stats {
+	stats := &Stats{
+		Files:       len(r.FoundFilesPaths),
+		ByRoot:      make(map[string]int),
+		ByExtension: make(map[string]int),
+	}
+	for _, p := range r.FoundFilesPaths {
+		stats.ByRoot[r.scanRootOf(p)]++
+		if !isURL(p) {
+			stats.ByExtension[strings.ToLower(fileExtension(p))]++
+		}
+		if t := r.Metadata[p]; t != nil && t.Duration > 0 {
+			stats.Duration += t.Duration
+		}
+	}
+	return stats
+}
@@ -0,0 +1,48 @@
+package m3ugen
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adeynack/m3ugen/pkg/tags"
+)
+
+// StreamConfig is an internet stream (eg: a radio) added to the scan result.
+type StreamConfig struct {
+	// URL of the stream.
+	URL string `json:"url"`
+	// Title of the stream, written in the `#EXTINF` line of extended M3U playlists.
+	Title string `json:"title"`
+	// Duration of the stream in seconds. 0 or -1 (live streams) means unknown.
+	Duration int `json:"duration"`
+}
+
+// Validate checks that the stream has a URL.
+func (s *StreamConfig) Validate() error {
+	if !isURL(s.URL) {
+		return fmt.Errorf("stream %q requires a URL", s.Title)
+	}
+	if s.Duration < -1 {
+		return fmt.Errorf("invalid duration %d for stream %q", s.Duration, s.URL)
+	}
+	return nil
+}
+
+// addStreams adds the configured streams after the scanned files, with their title and duration as metadata.
+func (r *ScanRun) addStreams() {
+	if len(r.Config.Streams) == 0 {
+		return
+	}
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]*tags.Tags)
+	}
+	for _, stream := range r.Config.Streams {
+		r.FoundFilesPaths = append(r.FoundFilesPaths, stream.URL)
+		t := &tags.Tags{Title: stream.Title}
+		if stream.Duration > 0 {
+			t.Duration = time.Duration(stream.Duration) * time.Second
+		}
+		r.Metadata[stream.URL] = t
+	}
+	r.verbose("Added %d streams", len(r.Config.Streams))
+}
@@ -0,0 +1,120 @@
+package m3ugen
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/adeynack/m3ugen/pkg/tags"
+)
+
+const (
+	templateHeader = "header"
+	templateEntry  = "entry"
+	templateFooter = "footer"
+)
+
+// TemplatePlaylist is the data given to the `header` and `footer` templates of the `template` format.
+type TemplatePlaylist struct {
+	// Path of the playlist being written.
+	Path string
+	// Count is the number of entries in the playlist.
+	Count int
+}
+
+// TemplateEntry is the data given to the `entry` template of the `template` format.
+type TemplateEntry struct {
+	// Path of the file, as found while scanning.
+	Path string
+	// RelativePath is the path of the file, relative to the playlist's folder.
+	RelativePath string
+	// URI is the `file://` URI of the file.
+	URI string
+	// Size of the file, in bytes.
+	Size int64
+	// ModTime is the last modification time of the file.
+	ModTime time.Time
+	// Index is the position of the entry in the playlist, starting at 1.
+	Index int
+	// Metadata holds the tags of the file. Nil when they were not read (see `read_tags`).
+	Metadata *tags.Tags
+}
+
+// playlistTemplate parses the `template` file, keeping it for the playlists to write (see
+// `outputTemplate`).
+func (o *OutputOptions) playlistTemplate() (*template.Template, error) {
+	if o.Template == "" {
+		return nil, fmt.Errorf("format %q requires a template file (template)", FormatTemplate)
+	}
+	tmpl, err := template.ParseFiles(o.Template)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	if tmpl.Lookup(templateEntry) == nil {
+		return nil, fmt.Errorf("template %q does not define an %q template", o.Template, templateEntry)
+	}
+	o.parsedTemplate = tmpl
+	return tmpl, nil
+}
+
+// outputTemplate returns the template parsed when the output was validated, only parsing it
+// when it was not.
+func (o *OutputOptions) outputTemplate() (*template.Template, error) {
+	if o.parsedTemplate != nil {
+		return o.parsedTemplate, nil
+	}
+	return o.playlistTemplate()
+}
+
+// renderTemplate writes the playlist using the output's `text/template` file.
+func (r *ScanRun) renderTemplate(w io.Writer, output *OutputConfig, playlistPath string, paths []string) error {
+	tmpl, err := output.outputTemplate()
+	if err != nil {
+		return err
+	}
+
+	playlist := TemplatePlaylist{Path: playlistPath, Count: len(paths)}
+	if tmpl.Lookup(templateHeader) != nil {
+		if err := tmpl.ExecuteTemplate(w, templateHeader, playlist); err != nil {
+			return err
+		}
+	}
+
+	relative := relativePaths(filepath.Dir(playlistPath), paths)
+	for i, p := range paths {
+		entry := TemplateEntry{
+			Path:         p,
+			RelativePath: relative[i],
+			URI:          fileURI(p),
+			Index:        i + 1,
+			Metadata:     r.Metadata[p],
+		}
+		if info, err := os.Stat(p); err == nil {
+			entry.Size = info.Size()
+			entry.ModTime = info.ModTime()
+		}
+		if err := tmpl.ExecuteTemplate(w, templateEntry, entry); err != nil {
+			return err
+		}
+	}
+
+	if tmpl.Lookup(templateFooter) != nil {
+		return tmpl.ExecuteTemplate(w, templateFooter, playlist)
+	}
+	return nil
+}
+
+// fileURI returns the `file://` URI of a path, or the location itself for URLs.
+func fileURI(p string) string {
+	if isURL(p) {
+		return p
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+}
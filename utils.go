@@ -1,6 +1,9 @@
 package m3ugen
 
-import "math/rand"
+import (
+	"math/rand"
+	"strings"
+)
 
 // FirstErr returns the first errors in a list.
 func FirstErr(first, second error, others ...error) error {
@@ -22,3 +25,42 @@ func FirstErr(first, second error, others ...error) error {
 func ShuffleSlice[T any](a []T) {
 	rand.Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
 }
+
+// lowerCaseAll returns a copy of a list of strings, all in lower case.
+func lowerCaseAll(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(v)
+	}
+	return lowered
+}
+
+// FirstNonEmpty returns the first of the `values` which is not an empty string.
+func FirstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// SanitizeFileName makes a value safe to use as a file name on common file systems, by
+// replacing reserved and control characters with `_` and trimming leading and trailing
+// spaces and dots.
+func SanitizeFileName(value string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, value)
+	sanitized = strings.Trim(sanitized, " .")
+	if sanitized == "" {
+		return "_"
+	}
+	return sanitized
+}